@@ -0,0 +1,212 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrEmptyAction  = errors.New("audit event action is required")
+	ErrEmptyActor   = errors.New("audit event actor is required")
+	ErrChainBroken  = errors.New("audit chain broken")
+	ErrHashMismatch = errors.New("audit entry hash mismatch")
+	ErrHeadMismatch = errors.New("audit chain does not match the known head")
+)
+
+// Logger appends audit events to a Sink as a hash chain.
+//
+// A Logger owns the chain of its sink, so every process should write to its own
+// sink (e.g. one redis stream per service instance) to keep the chain linear.
+type Logger struct {
+	sink Sink
+	mu   sync.Mutex
+	last *Entry
+	now  func() time.Time
+}
+
+// New returns an audit Logger which continues the chain already stored in sink
+func New(ctx context.Context, sink Sink) (*Logger, error) {
+	last, err := sink.Last(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load last audit entry: %w", err)
+	}
+
+	return &Logger{
+		sink: sink,
+		last: last,
+		now:  time.Now,
+	}, nil
+}
+
+// Log records the event as the next entry of the chain and returns the stored entry
+func (l *Logger) Log(ctx context.Context, event Event) (Entry, error) {
+	if event.Action == "" {
+		return Entry{}, ErrEmptyAction
+	}
+	if event.Actor.ID == "" {
+		return Entry{}, ErrEmptyActor
+	}
+
+	before, err := marshalState(event.Before)
+	if err != nil {
+		return Entry{}, err
+	}
+	after, err := marshalState(event.After)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := Entry{
+		Seq:       1,
+		Timestamp: l.now().UTC(),
+		Actor:     event.Actor,
+		Action:    event.Action,
+		Resource:  event.Resource,
+		Before:    before,
+		After:     after,
+		Metadata:  event.Metadata,
+	}
+	if l.last != nil {
+		entry.Seq = l.last.Seq + 1
+		entry.PrevHash = l.last.Hash
+	}
+
+	if entry.Hash, err = hashEntry(entry); err != nil {
+		return Entry{}, err
+	}
+
+	if err := l.sink.Append(ctx, entry); err != nil {
+		return Entry{}, err
+	}
+
+	l.last = &entry
+	return entry, nil
+}
+
+// Head returns the last entry of the chain, the zero Head before the first entry
+func (l *Logger) Head() Head {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.last == nil {
+		return Head{}
+	}
+	return Head{Seq: l.last.Seq, Hash: l.last.Hash}
+}
+
+// Verify checks that entries form an unbroken chain starting from the first entry.
+// It returns ErrHashMismatch for an edited entry and ErrChainBroken when an entry
+// was removed, inserted or reordered, wrapped with the sequence number at fault.
+//
+// The chain alone can't tell when its last entries were removed or when it was
+// rewritten from the start, use VerifyHead with a head kept outside of the sink.
+func Verify(entries []Entry) error {
+	var prev *Entry
+	for i := range entries {
+		entry := entries[i]
+
+		hash, err := hashEntry(entry)
+		if err != nil {
+			return err
+		}
+		if hash != entry.Hash {
+			return fmt.Errorf("%w at seq %d", ErrHashMismatch, entry.Seq)
+		}
+
+		if prev == nil {
+			if entry.Seq != 1 || entry.PrevHash != "" {
+				return fmt.Errorf("%w at seq %d: chain does not start at the first entry", ErrChainBroken, entry.Seq)
+			}
+		} else if entry.Seq != prev.Seq+1 || entry.PrevHash != prev.Hash {
+			return fmt.Errorf("%w at seq %d", ErrChainBroken, entry.Seq)
+		}
+
+		prev = &entry
+	}
+
+	return nil
+}
+
+/*
+VerifyHead checks the chain like Verify and that it contains head, a Head returned by
+Logger.Head earlier and kept outside of the sink. Entries logged after head are
+allowed. It returns ErrHeadMismatch when the entries of head were removed or the
+chain was rewritten.
+
+Example:
+
+	// when closing the day, store the head where the sink's writers can't change it
+	head := auditLogger.Head()
+	// later
+	err := audit.VerifyHead(entries, head)
+*/
+func VerifyHead(entries []Entry, head Head) error {
+	if err := Verify(entries); err != nil {
+		return err
+	}
+	if head.Seq == 0 {
+		return nil
+	}
+
+	// Verify checked that the entry of seq n is at index n-1
+	if uint64(len(entries)) < head.Seq {
+		return fmt.Errorf("%w: chain ends at seq %d before seq %d", ErrHeadMismatch, len(entries), head.Seq)
+	}
+	if entries[head.Seq-1].Hash != head.Hash {
+		return fmt.Errorf("%w at seq %d", ErrHeadMismatch, head.Seq)
+	}
+	return nil
+}
+
+// VerifySink reads every entry from sink and checks the chain with Verify
+func VerifySink(ctx context.Context, sink Sink) error {
+	entries, err := sink.Entries(ctx)
+	if err != nil {
+		return err
+	}
+
+	return Verify(entries)
+}
+
+// VerifySinkHead reads every entry from sink and checks the chain with VerifyHead
+func VerifySinkHead(ctx context.Context, sink Sink, head Head) error {
+	entries, err := sink.Entries(ctx)
+	if err != nil {
+		return err
+	}
+
+	return VerifyHead(entries, head)
+}
+
+// hashEntry returns the hex encoded sha256 of the entry without its own hash
+func hashEntry(entry Entry) (string, error) {
+	entry.Hash = ""
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func marshalState(state interface{}) (json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit state: %w", err)
+	}
+	return b, nil
+}
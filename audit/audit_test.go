@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mostakim64/golang-utils/redisutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type price struct {
+	Amount int `json:"amount"`
+}
+
+func newFileLogger(t *testing.T) (*Logger, *FileSink) {
+	sink := NewFileSink(filepath.Join(t.TempDir(), "audit.log"))
+	l, err := New(context.Background(), sink)
+	require.NoError(t, err)
+	return l, sink
+}
+
+func logEvents(t *testing.T, l *Logger, n int) []Entry {
+	var entries []Entry
+	for i := 0; i < n; i++ {
+		entry, err := l.Log(context.Background(), Event{
+			Actor:    Actor{ID: "user-1", Type: "admin"},
+			Action:   "price.override",
+			Resource: Resource{Type: "item", ID: "42"},
+			Before:   price{Amount: 100 + i},
+			After:    price{Amount: 90 + i},
+		})
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLogger_Log(t *testing.T) {
+	l, sink := newFileLogger(t)
+	entries := logEvents(t, l, 3)
+
+	assert.Equal(t, uint64(1), entries[0].Seq)
+	assert.Empty(t, entries[0].PrevHash)
+	assert.Equal(t, entries[0].Hash, entries[1].PrevHash)
+	assert.Equal(t, entries[1].Hash, entries[2].PrevHash)
+	assert.JSONEq(t, `{"amount":100}`, string(entries[0].Before))
+
+	stored, err := sink.Entries(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, stored, 3)
+	assert.NoError(t, Verify(stored))
+}
+
+func TestLogger_Log_invalid_event(t *testing.T) {
+	l, _ := newFileLogger(t)
+
+	_, err := l.Log(context.Background(), Event{Actor: Actor{ID: "user-1"}})
+	assert.ErrorIs(t, err, ErrEmptyAction)
+
+	_, err = l.Log(context.Background(), Event{Action: "login"})
+	assert.ErrorIs(t, err, ErrEmptyActor)
+}
+
+func TestNew_continues_chain(t *testing.T) {
+	l, sink := newFileLogger(t)
+	logEvents(t, l, 2)
+
+	resumed, err := New(context.Background(), sink)
+	require.NoError(t, err)
+	entries := logEvents(t, resumed, 1)
+
+	assert.Equal(t, uint64(3), entries[0].Seq)
+	assert.NoError(t, VerifySink(context.Background(), sink))
+}
+
+func TestVerify(t *testing.T) {
+	l, _ := newFileLogger(t)
+	entries := logEvents(t, l, 4)
+
+	t.Run("edited entry", func(t *testing.T) {
+		tampered := append([]Entry(nil), entries...)
+		tampered[1].After = json.RawMessage(`{"amount":1}`)
+		err := Verify(tampered)
+		assert.True(t, errors.Is(err, ErrHashMismatch))
+	})
+
+	t.Run("deleted entry", func(t *testing.T) {
+		tampered := append(append([]Entry(nil), entries[:1]...), entries[2:]...)
+		assert.ErrorIs(t, Verify(tampered), ErrChainBroken)
+	})
+
+	t.Run("deleted first entry", func(t *testing.T) {
+		assert.ErrorIs(t, Verify(entries[1:]), ErrChainBroken)
+	})
+
+	t.Run("reordered entries", func(t *testing.T) {
+		tampered := []Entry{entries[0], entries[2], entries[1], entries[3]}
+		assert.ErrorIs(t, Verify(tampered), ErrChainBroken)
+	})
+
+	t.Run("empty chain", func(t *testing.T) {
+		assert.NoError(t, Verify(nil))
+	})
+}
+
+func TestVerifyHead(t *testing.T) {
+	l, sink := newFileLogger(t)
+	assert.Equal(t, Head{}, l.Head())
+	entries := logEvents(t, l, 3)
+	head := l.Head()
+	assert.Equal(t, Head{Seq: 3, Hash: entries[2].Hash}, head)
+
+	assert.NoError(t, VerifyHead(entries, head))
+	assert.NoError(t, VerifySinkHead(context.Background(), sink, head))
+
+	t.Run("entries logged after the head", func(t *testing.T) {
+		assert.NoError(t, VerifyHead(append(entries, logEvents(t, l, 1)...), head))
+	})
+
+	t.Run("truncated chain", func(t *testing.T) {
+		assert.NoError(t, Verify(entries[:2]))
+		assert.ErrorIs(t, VerifyHead(entries[:2], head), ErrHeadMismatch)
+		assert.ErrorIs(t, VerifyHead(nil, head), ErrHeadMismatch)
+	})
+
+	t.Run("rewritten chain", func(t *testing.T) {
+		forged, _ := newFileLogger(t)
+		rewritten := logEvents(t, forged, 3)
+		assert.NoError(t, Verify(rewritten))
+		assert.ErrorIs(t, VerifyHead(rewritten, head), ErrHeadMismatch)
+	})
+
+	t.Run("broken chain", func(t *testing.T) {
+		assert.ErrorIs(t, VerifyHead(entries[1:], head), ErrChainBroken)
+	})
+}
+
+func TestRedisStreamSink(t *testing.T) {
+	mr := miniredis.RunT(t)
+	r, err := redisutil.New(redisutil.Options{Host: mr.Host(), Port: mr.Port(), Prefix: "svc:"})
+	require.NoError(t, err)
+
+	sink := NewRedisStreamSink(r, "audit")
+	l, err := New(context.Background(), sink)
+	require.NoError(t, err)
+	logEvents(t, l, 3)
+
+	assert.True(t, mr.Exists("svc:audit"))
+	resumed, err := New(context.Background(), sink)
+	require.NoError(t, err)
+	entries := logEvents(t, resumed, 1)
+	assert.Equal(t, uint64(4), entries[0].Seq)
+	assert.NoError(t, VerifySink(context.Background(), sink))
+}
@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mostakim64/golang-utils/redisutil"
+	"github.com/redis/go-redis/v9"
+)
+
+const entryField = "entry"
+
+// FileSink stores audit entries as json lines in a file
+type FileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileSink returns a Sink appending to the file at path, the file is created if it doesn't exist
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (s *FileSink) Append(_ context.Context, entry Entry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *FileSink) Last(ctx context.Context) (*Entry, error) {
+	entries, err := s.Entries(ctx)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+
+	return &entries[len(entries)-1], nil
+}
+
+func (s *FileSink) Entries(_ context.Context) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid audit entry at line %d: %w", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// RedisStreamSink stores audit entries in a redis stream, the stream key is prefixed with the redisutil Prefix
+type RedisStreamSink struct {
+	redis  *redisutil.Redis
+	stream string
+}
+
+// NewRedisStreamSink returns a Sink appending to the redis stream
+func NewRedisStreamSink(r *redisutil.Redis, stream string) *RedisStreamSink {
+	return &RedisStreamSink{
+		redis:  r,
+		stream: r.Prefix + stream,
+	}
+}
+
+func (s *RedisStreamSink) Append(ctx context.Context, entry Entry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return s.redis.RedisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.stream,
+		Values: map[string]interface{}{entryField: string(b)},
+	}).Err()
+}
+
+func (s *RedisStreamSink) Last(ctx context.Context) (*Entry, error) {
+	messages, err := s.redis.RedisClient.XRevRangeN(ctx, s.stream, "+", "-", 1).Result()
+	if err != nil || len(messages) == 0 {
+		return nil, err
+	}
+
+	entry, err := decodeStreamEntry(messages[0])
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (s *RedisStreamSink) Entries(ctx context.Context) ([]Entry, error) {
+	messages, err := s.redis.RedisClient.XRange(ctx, s.stream, "-", "+").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(messages))
+	for _, msg := range messages {
+		entry, err := decodeStreamEntry(msg)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func decodeStreamEntry(msg redis.XMessage) (Entry, error) {
+	var entry Entry
+	raw, ok := msg.Values[entryField].(string)
+	if !ok {
+		return entry, fmt.Errorf("invalid audit entry in stream message %s", msg.ID)
+	}
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		return entry, fmt.Errorf("invalid audit entry in stream message %s: %w", msg.ID, err)
+	}
+	return entry, nil
+}
+
+var (
+	_ Sink = (*FileSink)(nil)
+	_ Sink = (*RedisStreamSink)(nil)
+)
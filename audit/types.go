@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Actor is who performed the audited action
+type Actor struct {
+	ID   string `json:"id"`
+	Type string `json:"type,omitempty"`
+	IP   string `json:"ip,omitempty"`
+}
+
+// Resource is what the audited action was performed on
+type Resource struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// Event is a security relevant action to be recorded, e.g. a login,
+// a permission change or a price override
+type Event struct {
+	Actor    Actor
+	Action   string
+	Resource Resource
+	Before   interface{}
+	After    interface{}
+	Metadata map[string]string
+}
+
+// Entry is an Event as it is stored by a Sink. Every entry carries the hash of
+// the previous entry so that removing or editing an entry breaks the chain.
+type Entry struct {
+	Seq       uint64            `json:"seq"`
+	Timestamp time.Time         `json:"timestamp"`
+	Actor     Actor             `json:"actor"`
+	Action    string            `json:"action"`
+	Resource  Resource          `json:"resource"`
+	Before    json.RawMessage   `json:"before,omitempty"`
+	After     json.RawMessage   `json:"after,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	PrevHash  string            `json:"prev_hash"`
+	Hash      string            `json:"hash"`
+}
+
+// Head identifies the last entry of a chain. Kept outside of the sink, e.g. in a
+// separate store or a signed report, it lets VerifyHead detect a chain which was
+// truncated or entirely rewritten.
+type Head struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// Sink stores audit entries in the order they are appended
+type Sink interface {
+	// Append stores the entry after all previously appended entries
+	Append(ctx context.Context, entry Entry) error
+	// Last returns the most recently appended entry, nil if the sink is empty
+	Last(ctx context.Context) (*Entry, error)
+	// Entries returns all the stored entries in append order
+	Entries(ctx context.Context) ([]Entry, error)
+}
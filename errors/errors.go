@@ -0,0 +1,160 @@
+// Package errors is a drop-in companion to the standard errors package which
+// records the call stack at the point an error is created, so that loggers can
+// report where an error originated rather than where it was finally logged.
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"runtime"
+)
+
+const maxStackDepth = 32
+
+// stack holds the program counters captured when an error was created
+type stack []uintptr
+
+// withStack attaches a stack to an error without changing its message
+type withStack struct {
+	err   error
+	stack stack
+}
+
+func (w *withStack) Error() string { return w.err.Error() }
+
+func (w *withStack) Unwrap() error { return w.err }
+
+// New returns an error with the given message and the caller's stack attached.
+func New(message string) error {
+	return &withStack{
+		err:   stderrors.New(message),
+		stack: callers(3),
+	}
+}
+
+// Errorf formats according to a format specifier like fmt.Errorf, so %w keeps
+// wrapping the given error. The stack is only attached when none of the wrapped
+// errors carries one already, so the origin of the chain is preserved.
+func Errorf(format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	if hasStack(err) {
+		return err
+	}
+	return &withStack{
+		err:   err,
+		stack: callers(3),
+	}
+}
+
+// WithStack attaches the caller's stack to err. If err is nil or already
+// carries a stack anywhere in its chain, err is returned unchanged.
+func WithStack(err error) error {
+	if err == nil || hasStack(err) {
+		return err
+	}
+	return &withStack{
+		err:   err,
+		stack: callers(3),
+	}
+}
+
+// Wrap annotates err with message as "message: err" and keeps err in the chain.
+// A nil err returns nil.
+func Wrap(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	wrapped := fmt.Errorf("%s: %w", message, err)
+	if hasStack(err) {
+		return wrapped
+	}
+	return &withStack{
+		err:   wrapped,
+		stack: callers(3),
+	}
+}
+
+// Is reports whether any error in err's chain matches target.
+func Is(err, target error) bool {
+	return stderrors.Is(err, target)
+}
+
+// As finds the first error in err's chain that matches target.
+func As(err error, target interface{}) bool {
+	return stderrors.As(err, target)
+}
+
+// Unwrap returns the result of calling the Unwrap method on err.
+func Unwrap(err error) error {
+	return stderrors.Unwrap(err)
+}
+
+// Frames returns the stack recorded at the origin of err, that is the deepest
+// error in the chain which carries a stack. It returns nil when no error in the
+// chain was created by this package.
+func Frames(err error) []runtime.Frame {
+	var origin stack
+	walk(err, func(e error) {
+		if ws, ok := e.(*withStack); ok {
+			origin = ws.stack
+		}
+	})
+	if len(origin) == 0 {
+		return nil
+	}
+
+	var frames []runtime.Frame
+	iter := runtime.CallersFrames(origin)
+	for f, more := iter.Next(); ; f, more = iter.Next() {
+		frames = append(frames, f)
+		if !more {
+			break
+		}
+	}
+	return frames
+}
+
+// Chain returns the message of every error in err's chain, outermost first.
+// Errors joined together (an Unwrap() []error method) are flattened depth-first.
+func Chain(err error) []string {
+	var chain []string
+	walk(err, func(e error) {
+		if _, ok := e.(*withStack); ok {
+			return
+		}
+		chain = append(chain, e.Error())
+	})
+	return chain
+}
+
+// walk visits err and every error reachable through Unwrap, depth-first
+func walk(err error, visit func(error)) {
+	if err == nil {
+		return
+	}
+	visit(err)
+	switch e := err.(type) {
+	case interface{ Unwrap() error }:
+		walk(e.Unwrap(), visit)
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			walk(inner, visit)
+		}
+	}
+}
+
+func hasStack(err error) bool {
+	found := false
+	walk(err, func(e error) {
+		if _, ok := e.(*withStack); ok {
+			found = true
+		}
+	})
+	return found
+}
+
+func callers(skip int) stack {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip, pcs)
+	return pcs[:n]
+}
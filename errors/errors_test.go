@@ -0,0 +1,91 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errBase = stderrors.New("base")
+
+func newOrigin() error {
+	return New("origin")
+}
+
+func TestNew(t *testing.T) {
+	err := newOrigin()
+
+	assert.Equal(t, "origin", err.Error())
+	frames := Frames(err)
+	assert.NotEmpty(t, frames)
+	assert.True(t, strings.HasSuffix(frames[0].Function, "newOrigin"))
+}
+
+func TestErrorf_keeps_origin_stack(t *testing.T) {
+	origin := newOrigin()
+	err := Errorf("outer: %w", origin)
+
+	assert.Equal(t, "outer: origin", err.Error())
+	assert.True(t, Is(err, origin))
+	assert.True(t, strings.HasSuffix(Frames(err)[0].Function, "newOrigin"))
+}
+
+func TestWithStack(t *testing.T) {
+	t.Run("nil error", func(t *testing.T) {
+		assert.Nil(t, WithStack(nil))
+	})
+
+	t.Run("attaches stack to plain error", func(t *testing.T) {
+		err := WithStack(errBase)
+		assert.Equal(t, errBase.Error(), err.Error())
+		assert.True(t, Is(err, errBase))
+		assert.NotEmpty(t, Frames(err))
+	})
+
+	t.Run("keeps existing stack", func(t *testing.T) {
+		origin := newOrigin()
+		assert.Same(t, origin, WithStack(origin))
+	})
+}
+
+func TestWrap(t *testing.T) {
+	assert.Nil(t, Wrap(nil, "ignored"))
+
+	err := Wrap(errBase, "loading menu")
+	assert.Equal(t, "loading menu: base", err.Error())
+	assert.True(t, Is(err, errBase))
+	assert.NotEmpty(t, Frames(err))
+}
+
+func TestFrames_without_stack(t *testing.T) {
+	assert.Nil(t, Frames(errBase))
+	assert.Nil(t, Frames(nil))
+}
+
+func TestChain(t *testing.T) {
+	t.Run("wrapped chain", func(t *testing.T) {
+		err := fmt.Errorf("handler: %w", Wrap(errBase, "service"))
+		assert.Equal(t, []string{"handler: service: base", "service: base", "base"}, Chain(err))
+	})
+
+	t.Run("joined errors", func(t *testing.T) {
+		err := joined{errs: []error{errBase, New("other")}}
+		assert.Equal(t, []string{"joined", "base", "other"}, Chain(err))
+	})
+
+	t.Run("nil error", func(t *testing.T) {
+		assert.Nil(t, Chain(nil))
+	})
+}
+
+// joined mimics errors.Join without requiring go1.20
+type joined struct {
+	errs []error
+}
+
+func (j joined) Error() string { return "joined" }
+
+func (j joined) Unwrap() []error { return j.errs }
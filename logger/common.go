@@ -1,24 +1,53 @@
 package logger
 
 import (
-	"bytes"
+	"context"
 	"fmt"
-	"reflect"
-	"runtime"
 	"strings"
+	"sync/atomic"
 
 	"github.com/mostakim64/golang-utils/slackit"
 	"github.com/sirupsen/logrus"
 )
 
+// callerSkip is the number of stack frames between runtime.Caller in fileInfo
+// and the code calling a log function: fileInfo, the core method and the exported
+// method or package function.
+const callerSkip = 3
+
+var defaultLogger atomic.Pointer[KlikitLogger]
+
+func init() {
+	defaultLogger.Store(NewLoggerClient())
+}
+
+// Default returns the KlikitLogger used by the package level log functions
+func Default() *KlikitLogger {
+	return defaultLogger.Load()
+}
+
+// SetDefault makes l the KlikitLogger used by the package level log functions
+func SetDefault(l *KlikitLogger) {
+	defaultLogger.Store(l)
+}
+
 func NewLoggerClient() *KlikitLogger {
+	client := logrus.New()
 	return &KlikitLogger{
-		client: logrus.New(),
+		client: client,
+		levels: newLevelRegistry(client),
 	}
 }
 
+// SetLogLevel sets the level of the KlikitLogger and of the loggers derived from it
+// which don't have their own level. On a named logger it is the same as SetNamedLevel
+// with the name of the logger.
 func (r *KlikitLogger) SetLogLevel(level logrus.Level) {
-	r.client.Level = level
+	if r.name != "" {
+		r.levels.set(r.name, level)
+		return
+	}
+	r.levels.setBase(level)
 }
 
 func (r *KlikitLogger) SetLogFormatter(formatter logrus.Formatter) {
@@ -29,67 +58,60 @@ func (r *KlikitLogger) SetLogJsonFormatter() {
 	r.client.Formatter = &logrus.JSONFormatter{}
 }
 
+// Logrus returns the underlying logrus logger, e.g. to set the output or add hooks.
+// It is shared by all the named loggers derived from the KlikitLogger.
+func (r *KlikitLogger) Logrus() *logrus.Logger {
+	return r.client
+}
+
 // Debug logs a message at level Debug on the KlikitLogger.
 func (r *KlikitLogger) Debug(args ...interface{}) {
-	if r.client.Level >= logrus.DebugLevel {
-		entry := r.client.WithFields(logrus.Fields{})
-		//entry.Data["file"] = fileInfo(2)
-		entry.Debug(args...)
-	}
+	r.debug(args...)
 }
 
 // DebugWithFields Debug logs a message with fields at level Debug on the KlikitLogger.
 func (r *KlikitLogger) DebugWithFields(l interface{}, f map[string]interface{}) {
-	if r.client.Level >= logrus.DebugLevel {
-		entry := r.client.WithFields(f)
-		//entry.Data["file"] = fileInfo(2)
-		entry.Debug(l)
-	}
+	r.debugWithFields(l, f)
+}
+
+// DebugCtx logs a message at level Debug on the KlikitLogger with trace_id and span_id of the span in ctx.
+func (r *KlikitLogger) DebugCtx(ctx context.Context, args ...interface{}) {
+	r.debugCtx(ctx, args...)
 }
 
 // Info logs a message at level Info on the KlikitLogger.
 func (r *KlikitLogger) Info(args ...interface{}) {
-	if r.client.Level >= logrus.InfoLevel {
-		entry := r.client.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
-		entry.Info(args...)
-	}
+	r.info(args...)
 }
 
-// InfoWithFields Debug logs a message with fields at level Debug on the KlikitLogger.
+// InfoWithFields Debug logs a message with fields at level Info on the KlikitLogger.
 func (r *KlikitLogger) InfoWithFields(l interface{}, f map[string]interface{}) {
-	if r.client.Level >= logrus.InfoLevel {
-		entry := r.client.WithFields(f)
-		//entry.Data["file"] = fileInfo(2)
-		entry.Info(l)
-	}
+	r.infoWithFields(l, f)
+}
+
+// InfoCtx logs a message at level Info on the KlikitLogger with trace_id and span_id of the span in ctx.
+func (r *KlikitLogger) InfoCtx(ctx context.Context, args ...interface{}) {
+	r.infoCtx(ctx, args...)
 }
 
 // Warn logs a message at level Warn on the KlikitLogger.
 func (r *KlikitLogger) Warn(args ...interface{}) {
-	if r.client.Level >= logrus.WarnLevel {
-		entry := r.client.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
-		entry.Warn(args...)
-	}
+	r.warn(args...)
 }
 
-// WarnWithFields Debug logs a message with fields at level Debug on the KlikitLogger.
+// WarnWithFields Debug logs a message with fields at level Warn on the KlikitLogger.
 func (r *KlikitLogger) WarnWithFields(l interface{}, f map[string]interface{}) {
-	if r.client.Level >= logrus.WarnLevel {
-		entry := r.client.WithFields(f)
-		entry.Data["file"] = fileInfo(2)
-		entry.Warn(l)
-	}
+	r.warnWithFields(l, f)
+}
+
+// WarnCtx logs a message at level Warn on the KlikitLogger with trace_id and span_id of the span in ctx.
+func (r *KlikitLogger) WarnCtx(ctx context.Context, args ...interface{}) {
+	r.warnCtx(ctx, args...)
 }
 
 // StdError logs a message at level Error on the KlikitLogger.
 func (r *KlikitLogger) StdError(args ...interface{}) {
-	if r.client.Level >= logrus.ErrorLevel {
-		entry := r.client.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
-		entry.Error(args...)
-	}
+	r.stdError(args...)
 }
 
 // Error logs a message at level Error on the KlikitLogger and sends alert to slack
@@ -98,6 +120,144 @@ func (r *KlikitLogger) StdError(args ...interface{}) {
 //
 // if multiple items in args then 1st item will be treated as metadata and rest items will go for args
 func (r *KlikitLogger) Error(args ...interface{}) {
+	r.error(context.Background(), false, args...)
+}
+
+// ErrorWithTrace logs a message at level Error on the KlikitLogger with the call stack
+// of the caller and sends alert to slack.
+//
+// Same as Error, if multiple items in args then 1st item will be treated as metadata
+func (r *KlikitLogger) ErrorWithTrace(args ...interface{}) {
+	r.error(context.Background(), true, args...)
+}
+
+// ErrorCtx logs a message at level Error on the KlikitLogger with trace_id and span_id
+// of the span in ctx and sends alert to slack with a link to the trace.
+//
+// Same as Error, if multiple items in args then 1st item will be treated as metadata
+func (r *KlikitLogger) ErrorCtx(ctx context.Context, args ...interface{}) {
+	r.error(ctx, false, args...)
+}
+
+// ApiError logs a message at level Error on the KlikitLogger with request, response and metadata
+func (r *KlikitLogger) ApiError(rs RequestResponseMap, metaData interface{}, args ...interface{}) {
+	r.apiError(rs, metaData, args...)
+}
+
+// ErrorWithFields Debug logs a message with fields at level Error on the KlikitLogger.
+func (r *KlikitLogger) ErrorWithFields(l interface{}, f map[string]interface{}) {
+	r.errorWithFields(l, f)
+}
+
+// Fatal logs a message at level Fatal on the KlikitLogger.
+func (r *KlikitLogger) Fatal(args ...interface{}) {
+	r.fatal(args...)
+}
+
+// FatalWithFields Debug logs a message with fields at level Fatal on the KlikitLogger.
+func (r *KlikitLogger) FatalWithFields(l interface{}, f map[string]interface{}) {
+	r.fatalWithFields(l, f)
+}
+
+// Panic logs a message at level Panic on the KlikitLogger.
+func (r *KlikitLogger) Panic(args ...interface{}) {
+	r.panic(args...)
+}
+
+// PanicWithFields Debug logs a message with fields at level Panic on the KlikitLogger.
+func (r *KlikitLogger) PanicWithFields(l interface{}, f map[string]interface{}) {
+	r.panicWithFields(l, f)
+}
+
+// The methods below are the single implementation behind both the KlikitLogger
+// methods and the package level functions. They must only be called directly from
+// an exported function so that fileInfo(callerSkip) points at the caller.
+
+func (r *KlikitLogger) debug(args ...interface{}) {
+	if r.level() >= logrus.DebugLevel {
+		entry := r.entry()
+		//entry.Data["file"] = fileInfo(callerSkip)
+		entry.Debug(args...)
+	}
+}
+
+func (r *KlikitLogger) debugWithFields(l interface{}, f map[string]interface{}) {
+	if r.level() >= logrus.DebugLevel {
+		entry := r.entry().WithFields(f)
+		//entry.Data["file"] = fileInfo(callerSkip)
+		entry.Debug(l)
+	}
+}
+
+func (r *KlikitLogger) debugCtx(ctx context.Context, args ...interface{}) {
+	if r.level() >= logrus.DebugLevel {
+		entry := r.entry().WithContext(ctx)
+		withTraceFields(ctx, entry)
+		entry.Debug(args...)
+	}
+}
+
+func (r *KlikitLogger) info(args ...interface{}) {
+	if r.level() >= logrus.InfoLevel {
+		entry := r.entry()
+		entry.Data["file"] = fileInfo(callerSkip)
+		entry.Info(args...)
+	}
+}
+
+func (r *KlikitLogger) infoWithFields(l interface{}, f map[string]interface{}) {
+	if r.level() >= logrus.InfoLevel {
+		entry := r.entry().WithFields(f)
+		entry.Data["file"] = fileInfo(callerSkip)
+		entry.Info(l)
+	}
+}
+
+func (r *KlikitLogger) infoCtx(ctx context.Context, args ...interface{}) {
+	if r.level() >= logrus.InfoLevel {
+		entry := r.entry().WithContext(ctx)
+		entry.Data["file"] = fileInfo(callerSkip)
+		withTraceFields(ctx, entry)
+		entry.Info(args...)
+	}
+}
+
+func (r *KlikitLogger) warn(args ...interface{}) {
+	if r.level() >= logrus.WarnLevel {
+		entry := r.entry()
+		entry.Data["file"] = fileInfo(callerSkip)
+		entry.Warn(args...)
+	}
+}
+
+func (r *KlikitLogger) warnWithFields(l interface{}, f map[string]interface{}) {
+	if r.level() >= logrus.WarnLevel {
+		entry := r.entry().WithFields(f)
+		entry.Data["file"] = fileInfo(callerSkip)
+		entry.Warn(l)
+	}
+}
+
+func (r *KlikitLogger) warnCtx(ctx context.Context, args ...interface{}) {
+	if r.level() >= logrus.WarnLevel {
+		entry := r.entry().WithContext(ctx)
+		entry.Data["file"] = fileInfo(callerSkip)
+		withTraceFields(ctx, entry)
+		entry.Warn(args...)
+	}
+}
+
+func (r *KlikitLogger) stdError(args ...interface{}) {
+	if r.level() >= logrus.ErrorLevel {
+		entry := r.entry()
+		entry.Data["file"] = fileInfo(callerSkip)
+		withErrorDetails(entry, args...)
+		entry.Error(args...)
+	}
+}
+
+// error is the core of Error, ErrorWithTrace and ErrorCtx, withTrace adds the call stack of the caller
+func (r *KlikitLogger) error(ctx context.Context, withTrace bool, args ...interface{}) {
 	var metaData interface{}
 
 	if len(args) > 1 {
@@ -105,33 +265,47 @@ func (r *KlikitLogger) Error(args ...interface{}) {
 		args = args[1:]
 	}
 
-	if r.client.Level >= logrus.ErrorLevel {
-		entry := r.client.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
+	if r.level() >= logrus.ErrorLevel {
+		entry := r.entry().WithContext(ctx)
+		entry.Data["file"] = fileInfo(callerSkip)
+		var tracer []string
+		if withTrace {
+			tracer = getLogCaller(callerSkip + 1)
+			entry.Data["trace"] = strings.Join(tracer, "; ")
+		}
+		traceID, spanID := withTraceFields(ctx, entry)
+		chain, stack := withErrorDetails(entry, args...)
 		entry.Error(args...)
+		r.recordSpanError(ctx, fileInfo(callerSkip), args...)
 
 		slackLogReq := SlacklogRequest{
-			Message: fmt.Sprint(args...),
-			File:    fileAddressInfo(2),
-			Level:   "error",
+			Message:    fmt.Sprint(args...),
+			File:       fileAddressInfo(callerSkip),
+			Level:      "error",
+			Logger:     r.name,
+			Fields:     r.slackFields(),
+			Trace:      tracer,
+			ErrorChain: chain,
+			ErrorStack: stack,
+			TraceID:    traceID,
+			SpanID:     spanID,
 		}
-		if err := ProcessAndSendWithMeta(slackLogReq, metaData, slackit.Alert, "Error"); err != nil {
-			r.Warn(err)
+		if err := r.ProcessAndSendWithMeta(slackLogReq, metaData, slackit.Alert, "Error"); err != nil {
+			r.warn(err)
 		}
 	}
 }
 
-// Error logs a message at level Error on the KlikitLogger. with res data and metaData
-func (r *KlikitLogger) ApiError(rs RequestResponseMap, metaData interface{}, args ...interface{}) {
-	if r.client.Level >= logrus.ErrorLevel {
-		entry := logger.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
+func (r *KlikitLogger) apiError(rs RequestResponseMap, metaData interface{}, args ...interface{}) {
+	if r.level() >= logrus.ErrorLevel {
+		entry := r.entry()
+		entry.Data["file"] = fileInfo(callerSkip)
 		entry.Error(args...)
 		whichApi := args[0].(string)
 
 		slackLogReq := SlacklogRequestWithApiError{
 			Message: fmt.Sprint(args...) + " Failed",
-			File:    fileAddressInfo(2),
+			File:    fileAddressInfo(callerSkip),
 			Level:   "error",
 			ApiDetails: slackit.ApiError{
 				Api: whichApi,
@@ -145,105 +319,66 @@ func (r *KlikitLogger) ApiError(rs RequestResponseMap, metaData interface{}, arg
 			},
 		}
 
-		if err := ProcessAndSendWithApiError(slackLogReq, metaData, slackit.Alert, "Error"); err != nil {
-			r.Warn(err)
+		if err := r.ProcessAndSendWithApiError(slackLogReq, metaData, slackit.Alert, "Error"); err != nil {
+			r.warn(err)
 		}
 	}
 }
 
-// ErrorWithFields Debug logs a message with fields at level Debug on the KlikitLogger.
-func (r *KlikitLogger) ErrorWithFields(l interface{}, f map[string]interface{}) {
-	if r.client.Level >= logrus.ErrorLevel {
-		entry := r.client.WithFields(f)
-		entry.Data["file"] = fileInfo(2)
+func (r *KlikitLogger) errorWithFields(l interface{}, f map[string]interface{}) {
+	if r.level() >= logrus.ErrorLevel {
+		entry := r.entry().WithFields(f)
+		entry.Data["file"] = fileInfo(callerSkip)
+		withErrorDetails(entry, l)
 		entry.Error(l)
 	}
 }
 
-// Fatal logs a message at level Fatal on the KlikitLogger.
-func (r *KlikitLogger) Fatal(args ...interface{}) {
-	if r.client.Level >= logrus.FatalLevel {
+func (r *KlikitLogger) fatal(args ...interface{}) {
+	if r.level() >= logrus.FatalLevel {
 		slackLogReq := SlacklogRequest{
 			Message: fmt.Sprint(args...),
-			File:    fileAddressInfo(2),
+			File:    fileAddressInfo(callerSkip),
 			Level:   "fatal",
+			Logger:  r.name,
+			Fields:  r.slackFields(),
 		}
-		_ = ProcessAndSend(slackLogReq, slackit.Alert, "Fatal")
-		entry := r.client.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
+		_ = r.ProcessAndSend(slackLogReq, slackit.Alert, "Fatal")
+		entry := r.entry()
+		entry.Data["file"] = fileInfo(callerSkip)
 		entry.Fatal(args...)
-
 	}
 }
 
-// FatalWithFields Debug logs a message with fields at level Debug on the KlikitLogger.
-func (r *KlikitLogger) FatalWithFields(l interface{}, f map[string]interface{}) {
-	if r.client.Level >= logrus.FatalLevel {
-		entry := r.client.WithFields(f)
-		entry.Data["file"] = fileInfo(2)
+func (r *KlikitLogger) fatalWithFields(l interface{}, f map[string]interface{}) {
+	if r.level() >= logrus.FatalLevel {
+		entry := r.entry().WithFields(f)
+		entry.Data["file"] = fileInfo(callerSkip)
 		entry.Fatal(l)
 	}
 }
 
-// Panic logs a message at level Panic on the KlikitLogger.
-func (r *KlikitLogger) Panic(args ...interface{}) {
-	if r.client.Level >= logrus.PanicLevel {
-
+func (r *KlikitLogger) panic(args ...interface{}) {
+	if r.level() >= logrus.PanicLevel {
 		slackLogReq := SlacklogRequest{
 			Message: fmt.Sprint(args...),
-			File:    fileAddressInfo(2),
+			File:    fileAddressInfo(callerSkip),
 			Level:   "panic",
+			Logger:  r.name,
+			Fields:  r.slackFields(),
 		}
-		_ = ProcessAndSend(slackLogReq, slackit.Alert, "Panic")
+		_ = r.ProcessAndSend(slackLogReq, slackit.Alert, "Panic")
 
-		entry := r.client.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
+		entry := r.entry()
+		entry.Data["file"] = fileInfo(callerSkip)
 		entry.Panic(args...)
-
 	}
 }
 
-// PanicWithFields Debug logs a message with fields at level Debug on the KlikitLogger.
-func (r *KlikitLogger) PanicWithFields(l interface{}, f fields) {
-	if r.client.Level >= logrus.PanicLevel {
-		entry := r.client.WithFields(logrus.Fields(f))
-		entry.Data["file"] = fileInfo(2)
+func (r *KlikitLogger) panicWithFields(l interface{}, f map[string]interface{}) {
+	if r.level() >= logrus.PanicLevel {
+		entry := r.entry().WithFields(f)
+		entry.Data["file"] = fileInfo(callerSkip)
 		entry.Panic(l)
 	}
 }
-
-func (r *KlikitLogger) fileInfo(skip int) string {
-	_, file, line, ok := runtime.Caller(skip)
-	if !ok {
-		file = "<???>"
-		line = 1
-	} else {
-		slash := strings.LastIndex(file, "/")
-		if slash >= 0 {
-			file = file[slash+1:]
-		}
-	}
-	return fmt.Sprintf("%s:%d", file, line)
-}
-
-func (r *KlikitLogger) fileAddressInfo(skip int) string {
-	_, file, line, _ := runtime.Caller(skip)
-	return fmt.Sprintf("%s:%d", file, line)
-}
-
-func (r *KlikitLogger) processLog(args ...interface{}) string {
-	var errMsgBuffer bytes.Buffer
-	for _, arg := range args {
-		if arg != nil {
-			switch reflect.TypeOf(arg).Kind() {
-			case reflect.String:
-				errMsgBuffer.WriteString(arg.(string) + "\n")
-			case reflect.Ptr:
-				e := arg.(error)
-				errMsgBuffer.WriteString(e.Error() + "\n")
-			}
-		}
-	}
-
-	return errMsgBuffer.String()
-}
@@ -2,90 +2,78 @@ package logger
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"reflect"
 	"runtime"
 	"strings"
 
-	"github.com/mostakim64/golang-utils/slackit"
+	"github.com/mostakim64/golang-utils/errors"
 	"github.com/sirupsen/logrus"
 )
 
-var logger = logrus.New()
+// The package level functions below log through the default KlikitLogger, see SetDefault.
 
 func SetLogLevel(level logrus.Level) {
-	logger.Level = level
+	Default().SetLogLevel(level)
 }
 
 func SetLogFormatter(formatter logrus.Formatter) {
-	logger.Formatter = formatter
+	Default().SetLogFormatter(formatter)
 }
 
 func SetLogJsonFormatter() {
-	logger.Formatter = &logrus.JSONFormatter{}
+	Default().SetLogJsonFormatter()
 }
 
 // Debug logs a message at level Debug on the standard logger.
 func Debug(args ...interface{}) {
-	if logger.Level >= logrus.DebugLevel {
-		entry := logger.WithFields(logrus.Fields{})
-		//entry.Data["file"] = fileInfo(2)
-		entry.Debug(args...)
-	}
+	Default().debug(args...)
 }
 
 // DebugWithFields Debug logs a message with fields at level Debug on the standard logger.
-func DebugWithFields(l interface{}, f fields) {
-	if logger.Level >= logrus.DebugLevel {
-		entry := logger.WithFields(logrus.Fields(f))
-		//entry.Data["file"] = fileInfo(2)
-		entry.Debug(l)
-	}
+func DebugWithFields(l interface{}, f map[string]interface{}) {
+	Default().debugWithFields(l, f)
+}
+
+// DebugCtx logs a message at level Debug on the standard logger with trace_id and span_id of the span in ctx.
+func DebugCtx(ctx context.Context, args ...interface{}) {
+	Default().debugCtx(ctx, args...)
 }
 
 // Info logs a message at level Info on the standard logger.
 func Info(args ...interface{}) {
-	if logger.Level >= logrus.InfoLevel {
-		entry := logger.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
-		entry.Info(args...)
-	}
+	Default().info(args...)
 }
 
-// InfoWithFields Debug logs a message with fields at level Debug on the standard logger.
-func InfoWithFields(l interface{}, f fields) {
-	if logger.Level >= logrus.InfoLevel {
-		entry := logger.WithFields(logrus.Fields(f))
-		//entry.Data["file"] = fileInfo(2)
-		entry.Info(l)
-	}
+// InfoWithFields Debug logs a message with fields at level Info on the standard logger.
+func InfoWithFields(l interface{}, f map[string]interface{}) {
+	Default().infoWithFields(l, f)
+}
+
+// InfoCtx logs a message at level Info on the standard logger with trace_id and span_id of the span in ctx.
+func InfoCtx(ctx context.Context, args ...interface{}) {
+	Default().infoCtx(ctx, args...)
 }
 
 // Warn logs a message at level Warn on the standard logger.
 func Warn(args ...interface{}) {
-	if logger.Level >= logrus.WarnLevel {
-		entry := logger.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
-		entry.Warn(args...)
-	}
+	Default().warn(args...)
 }
 
-// WarnWithFields Debug logs a message with fields at level Debug on the standard logger.
-func WarnWithFields(l interface{}, f fields) {
-	if logger.Level >= logrus.WarnLevel {
-		entry := logger.WithFields(logrus.Fields(f))
-		entry.Data["file"] = fileInfo(2)
-		entry.Warn(l)
-	}
+// WarnWithFields Debug logs a message with fields at level Warn on the standard logger.
+func WarnWithFields(l interface{}, f map[string]interface{}) {
+	Default().warnWithFields(l, f)
+}
+
+// WarnCtx logs a message at level Warn on the standard logger with trace_id and span_id of the span in ctx.
+func WarnCtx(ctx context.Context, args ...interface{}) {
+	Default().warnCtx(ctx, args...)
 }
 
 // StdError logs a message at level Error on the standard logger.
 func StdError(args ...interface{}) {
-	if logger.Level >= logrus.ErrorLevel {
-		entry := logger.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
-		entry.Error(args...)
-	}
+	Default().stdError(args...)
 }
 
 // Error logs a message at level Error on the standard logger and sends alert to slack.
@@ -94,144 +82,53 @@ func StdError(args ...interface{}) {
 //
 // if multiple items in args then 1st item will be treated as metadata and rest items will go for args
 func Error(args ...interface{}) {
-	var metaData interface{}
-
-	if len(args) > 1 {
-		metaData = args[0]
-		args = args[1:]
-	}
-
-	if logger.Level >= logrus.ErrorLevel {
-		entry := logger.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
-		entry.Error(args...)
-		slackLogReq := SlacklogRequest{
-			Message: fmt.Sprint(args...),
-			File:    fileAddressInfo(2),
-			Level:   "error",
-		}
-		if err := ProcessAndSendWithMeta(slackLogReq, metaData, slackit.Alert, "Error"); err != nil {
-			Warn(err)
-		}
-	}
+	Default().error(context.Background(), false, args...)
 }
 
+// ErrorWithTrace logs a message at level Error on the standard logger with the call stack
+// of the caller and sends alert to slack.
+//
+// Same as Error, if multiple items in args then 1st item will be treated as metadata
 func ErrorWithTrace(args ...interface{}) {
-	var metaData interface{}
-
-	if len(args) > 1 {
-		metaData = args[0]
-		args = args[1:]
-	}
+	Default().error(context.Background(), true, args...)
+}
 
-	if logger.Level >= logrus.ErrorLevel {
-		entry := logger.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
-		tracer := getLogCaller(2)
-		entry.Data["trace"] = strings.Join(tracer, "; ")
-		entry.Error(args...)
-		slackLogReq := SlacklogRequest{
-			Message: fmt.Sprint(args...),
-			File:    fileAddressInfo(2),
-			Level:   "error",
-			Trace:   tracer,
-		}
-		if err := ProcessAndSendWithMeta(slackLogReq, metaData, slackit.Alert, "Error"); err != nil {
-			Warn(err)
-		}
-	}
+// ErrorCtx logs a message at level Error on the standard logger with trace_id and span_id
+// of the span in ctx and sends alert to slack with a link to the trace.
+//
+// Same as Error, if multiple items in args then 1st item will be treated as metadata
+func ErrorCtx(ctx context.Context, args ...interface{}) {
+	Default().error(ctx, false, args...)
 }
 
-// Error logs a message at level Error on the standard logger with request, response and metadata
+// ApiError logs a message at level Error on the standard logger with request, response and metadata
 func ApiError(rs RequestResponseMap, metaData interface{}, args ...interface{}) {
-	if logger.Level >= logrus.ErrorLevel {
-		entry := logger.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
-		entry.Error(args...)
-		whichApi := args[0].(string)
-
-		slackLogReq := SlacklogRequestWithApiError{
-			Message: fmt.Sprint(args...) + " Failed",
-			File:    fileAddressInfo(2),
-			Level:   "error",
-			ApiDetails: slackit.ApiError{
-				Api: whichApi,
-				Url: rs.Req.URL.String(),
-				Data: slackit.Data{
-					Status:       rs.Res.StatusCode,
-					Headers:      rs.Req.Header,
-					RequestBody:  rs.ReqBody,
-					ResponseBody: rs.ResBody,
-				},
-			},
-		}
-
-		if err := ProcessAndSendWithApiError(slackLogReq, metaData, slackit.Alert, "Error"); err != nil {
-			Warn(err)
-		}
-
-	}
+	Default().apiError(rs, metaData, args...)
 }
 
-// ErrorWithFields Debug logs a message with fields at level Debug on the standard logger.
-func ErrorWithFields(l interface{}, f fields) {
-	if logger.Level >= logrus.ErrorLevel {
-		entry := logger.WithFields(logrus.Fields(f))
-		entry.Data["file"] = fileInfo(2)
-		entry.Error(l)
-	}
+// ErrorWithFields Debug logs a message with fields at level Error on the standard logger.
+func ErrorWithFields(l interface{}, f map[string]interface{}) {
+	Default().errorWithFields(l, f)
 }
 
 // Fatal logs a message at level Fatal on the standard logger.
 func Fatal(args ...interface{}) {
-	if logger.Level >= logrus.FatalLevel {
-		slackLogReq := SlacklogRequest{
-			Message: fmt.Sprint(args...),
-			File:    fileAddressInfo(2),
-			Level:   "fatal",
-		}
-		_ = ProcessAndSend(slackLogReq, slackit.Alert, "Fatal")
-		entry := logger.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
-		entry.Fatal(args...)
-
-	}
+	Default().fatal(args...)
 }
 
-// FatalWithFields Debug logs a message with fields at level Debug on the standard logger.
-func FatalWithFields(l interface{}, f fields) {
-	if logger.Level >= logrus.FatalLevel {
-		entry := logger.WithFields(logrus.Fields(f))
-		entry.Data["file"] = fileInfo(2)
-		entry.Fatal(l)
-	}
+// FatalWithFields Debug logs a message with fields at level Fatal on the standard logger.
+func FatalWithFields(l interface{}, f map[string]interface{}) {
+	Default().fatalWithFields(l, f)
 }
 
 // Panic logs a message at level Panic on the standard logger.
 func Panic(args ...interface{}) {
-	if logger.Level >= logrus.PanicLevel {
-
-		slackLogReq := SlacklogRequest{
-			Message: fmt.Sprint(args...),
-			File:    fileAddressInfo(2),
-			Level:   "panic",
-		}
-		_ = ProcessAndSend(slackLogReq, slackit.Alert, "Panic")
-
-		entry := logger.WithFields(logrus.Fields{})
-		entry.Data["file"] = fileInfo(2)
-		entry.Panic(args...)
-
-	}
+	Default().panic(args...)
 }
 
-// PanicWithFields Debug logs a message with fields at level Debug on the standard logger.
-func PanicWithFields(l interface{}, f fields) {
-	if logger.Level >= logrus.PanicLevel {
-		entry := logger.WithFields(logrus.Fields(f))
-		entry.Data["file"] = fileInfo(2)
-		entry.Panic(l)
-	}
+// PanicWithFields Debug logs a message with fields at level Panic on the standard logger.
+func PanicWithFields(l interface{}, f map[string]interface{}) {
+	Default().panicWithFields(l, f)
 }
 
 func fileInfo(skip int) string {
@@ -255,23 +152,69 @@ func getLogCaller(skip int) []string {
 
 	var files []string
 
-	acceptedPrefix := "github.com/klikit"
-
 	for f, again := frames.Next(); again; f, again = frames.Next() {
-		fileName := getFileName(f.File)
-		if strings.Contains(fileName, acceptedPrefix) {
-			files = append(files, fmt.Sprintf("%s:%d", strings.TrimPrefix(fileName, acceptedPrefix), f.Line))
-		} else {
-			if strings.Contains(fileName, "src/runtime") {
-				continue
-			}
-			files = append(files, fmt.Sprintf("%s:%d", fileName, f.Line))
+		if file, ok := frameInfo(f); ok {
+			files = append(files, file)
 		}
 	}
 
 	return files
 }
 
+// frameInfo formats a stack frame as file:line, skipping go runtime frames
+func frameInfo(f runtime.Frame) (string, bool) {
+	acceptedPrefix := "github.com/klikit"
+
+	fileName := getFileName(f.File)
+	if strings.Contains(fileName, acceptedPrefix) {
+		return fmt.Sprintf("%s:%d", strings.TrimPrefix(fileName, acceptedPrefix), f.Line), true
+	}
+	if strings.Contains(fileName, "src/runtime") {
+		return "", false
+	}
+	return fmt.Sprintf("%s:%d", fileName, f.Line), true
+}
+
+// errorDetails finds the first error in args and returns the messages of its
+// wrapped chain along with the stack recorded where the error was created
+func errorDetails(args ...interface{}) ([]string, []string) {
+	err, ok := firstError(args...)
+	if !ok {
+		return nil, nil
+	}
+
+	var stack []string
+	for _, f := range errors.Frames(err) {
+		if file, ok := frameInfo(f); ok {
+			stack = append(stack, file)
+		}
+	}
+	return errors.Chain(err), stack
+}
+
+// firstError returns the first non nil error in args
+func firstError(args ...interface{}) (error, bool) {
+	for _, arg := range args {
+		if err, ok := arg.(error); ok && err != nil {
+			return err, true
+		}
+	}
+
+	return nil, false
+}
+
+// withErrorDetails adds error.chain and error.stack to the entry when args hold an error
+func withErrorDetails(entry *logrus.Entry, args ...interface{}) ([]string, []string) {
+	chain, stack := errorDetails(args...)
+	if len(chain) > 1 {
+		entry.Data["error.chain"] = chain
+	}
+	if len(stack) > 0 {
+		entry.Data["error.stack"] = strings.Join(stack, "; ")
+	}
+	return chain, stack
+}
+
 func getFileName(file string) string {
 	parts := strings.Split(file, "/")
 	for i := len(parts) - 1; i >= 0; i-- {
@@ -0,0 +1,179 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/mostakim64/golang-utils/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// slackRecorder is a fake slack webhook which records the received request bodies
+type slackRecorder struct {
+	mu     sync.Mutex
+	bodies []string
+	server *httptest.Server
+}
+
+func newSlackRecorder(t *testing.T) *slackRecorder {
+	rec := &slackRecorder{}
+	rec.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		rec.bodies = append(rec.bodies, string(b))
+		rec.mu.Unlock()
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(rec.server.Close)
+	return rec
+}
+
+var (
+	createdAt = regexp.MustCompile(`\*Created At:\*\\n[0-9: -]+`)
+	// the call stack above the logging line differs between the package and instance calls
+	traceCallers = regexp.MustCompile(`(logger_test.go:\d+)(?:; [^"]+|\\",(?:\\n\\t\\t\\"[^\\]+\\",?)+)`)
+)
+
+func (s *slackRecorder) take() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bodies := make([]string, 0, len(s.bodies))
+	for _, b := range s.bodies {
+		b = createdAt.ReplaceAllString(b, "")
+		bodies = append(bodies, traceCallers.ReplaceAllString(b, "$1"))
+	}
+	s.bodies = nil
+	return bodies
+}
+
+func newTestLogger(t *testing.T, out io.Writer) (*KlikitLogger, *slackRecorder) {
+	slack := newSlackRecorder(t)
+	l := NewLoggerClient()
+	l.SetLogFormatter(&logrus.JSONFormatter{DisableTimestamp: true})
+	l.SetLogLevel(logrus.TraceLevel)
+	l.SetSlackLogger(slack.server.URL, "conformance")
+	l.SetTraceURLTemplate("https://traces.example.com/{trace_id}")
+	l.Logrus().SetOutput(out)
+	l.Logrus().ExitFunc = func(int) {}
+	return l, slack
+}
+
+// run calls fn and swallows the panic raised by Panic level logs
+func run(fn func()) {
+	defer func() { _ = recover() }()
+	fn()
+}
+
+// The package level function and the method of each case are called from the same
+// line, so the file info of both logs must be identical.
+func TestPackageFunctionsConformToKlikitLogger(t *testing.T) {
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	err := fmt.Errorf("handler: %w", errors.New("db down"))
+	f := map[string]interface{}{"brand_id": 12}
+	rs := RequestResponseMap{
+		Req:     &http.Request{URL: &url.URL{Scheme: "https", Host: "example.com", Path: "/orders"}, Header: http.Header{}},
+		ReqBody: map[string]string{"id": "1"},
+		Res:     &http.Response{StatusCode: http.StatusBadGateway},
+		ResBody: "bad gateway",
+	}
+
+	tests := []struct {
+		name string
+		pkg  func()
+		inst func(l *KlikitLogger)
+	}{
+		{"Debug", func() { Debug("msg", 1) }, func(l *KlikitLogger) { l.Debug("msg", 1) }},
+		{"DebugWithFields", func() { DebugWithFields("msg", f) }, func(l *KlikitLogger) { l.DebugWithFields("msg", f) }},
+		{"DebugCtx", func() { DebugCtx(ctx, "msg") }, func(l *KlikitLogger) { l.DebugCtx(ctx, "msg") }},
+		{"Info", func() { Info("msg", 1) }, func(l *KlikitLogger) { l.Info("msg", 1) }},
+		{"InfoWithFields", func() { InfoWithFields("msg", f) }, func(l *KlikitLogger) { l.InfoWithFields("msg", f) }},
+		{"InfoCtx", func() { InfoCtx(ctx, "msg") }, func(l *KlikitLogger) { l.InfoCtx(ctx, "msg") }},
+		{"Warn", func() { Warn("msg", 1) }, func(l *KlikitLogger) { l.Warn("msg", 1) }},
+		{"WarnWithFields", func() { WarnWithFields("msg", f) }, func(l *KlikitLogger) { l.WarnWithFields("msg", f) }},
+		{"WarnCtx", func() { WarnCtx(ctx, "msg") }, func(l *KlikitLogger) { l.WarnCtx(ctx, "msg") }},
+		{"StdError", func() { StdError(err) }, func(l *KlikitLogger) { l.StdError(err) }},
+		{"Error", func() { Error(err) }, func(l *KlikitLogger) { l.Error(err) }},
+		{"Error with metadata", func() { Error(f, "msg", err) }, func(l *KlikitLogger) { l.Error(f, "msg", err) }},
+		{"ErrorWithTrace", func() { ErrorWithTrace(f, err) }, func(l *KlikitLogger) { l.ErrorWithTrace(f, err) }},
+		{"ErrorCtx", func() { ErrorCtx(ctx, err) }, func(l *KlikitLogger) { l.ErrorCtx(ctx, err) }},
+		{"ApiError", func() { ApiError(rs, f, "orders") }, func(l *KlikitLogger) { l.ApiError(rs, f, "orders") }},
+		{"ErrorWithFields", func() { ErrorWithFields(err, f) }, func(l *KlikitLogger) { l.ErrorWithFields(err, f) }},
+		{"Fatal", func() { Fatal("msg") }, func(l *KlikitLogger) { l.Fatal("msg") }},
+		{"FatalWithFields", func() { FatalWithFields("msg", f) }, func(l *KlikitLogger) { l.FatalWithFields("msg", f) }},
+		{"Panic", func() { Panic("msg") }, func(l *KlikitLogger) { l.Panic("msg") }},
+		{"PanicWithFields", func() { PanicWithFields("msg", f) }, func(l *KlikitLogger) { l.PanicWithFields("msg", f) }},
+	}
+
+	previous := Default()
+	t.Cleanup(func() { SetDefault(previous) })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pkgOut, instOut bytes.Buffer
+			pkgLogger, pkgSlack := newTestLogger(t, &pkgOut)
+			instLogger, instSlack := newTestLogger(t, &instOut)
+
+			SetDefault(pkgLogger)
+			run(tt.pkg)
+			run(func() { tt.inst(instLogger) })
+
+			require.NotEmpty(t, pkgOut.String())
+			assert.Equal(t, traceCallers.ReplaceAllString(pkgOut.String(), "$1"), traceCallers.ReplaceAllString(instOut.String(), "$1"))
+			assert.Equal(t, pkgSlack.take(), instSlack.take())
+		})
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	previous := Default()
+	t.Cleanup(func() { SetDefault(previous) })
+
+	var out bytes.Buffer
+	l, _ := newTestLogger(t, &out)
+	SetDefault(l)
+	SetLogLevel(logrus.WarnLevel)
+
+	Info("skipped")
+	Warn("written")
+
+	assert.Same(t, l, Default())
+	assert.Equal(t, logrus.WarnLevel, l.Logrus().Level)
+	assert.NotContains(t, out.String(), "skipped")
+	assert.Contains(t, out.String(), "written")
+}
+
+func TestKlikitLogger_slack_is_per_instance(t *testing.T) {
+	var out bytes.Buffer
+	first, firstSlack := newTestLogger(t, &out)
+	second, secondSlack := newTestLogger(t, &out)
+	second.SetSlackLogger(secondSlack.server.URL, "second-service")
+
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	}))
+	first.Error("first failed")
+	second.ErrorCtx(ctx, "second failed")
+
+	firstBodies, secondBodies := firstSlack.take(), secondSlack.take()
+	require.Len(t, firstBodies, 1)
+	require.Len(t, secondBodies, 1)
+	assert.Contains(t, firstBodies[0], "conformance")
+	assert.Contains(t, secondBodies[0], "second-service")
+	assert.Contains(t, secondBodies[0], "https://traces.example.com/01000000000000000000000000000000")
+}
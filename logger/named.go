@@ -0,0 +1,219 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mostakim64/golang-utils/slackit"
+	"github.com/sirupsen/logrus"
+)
+
+// levelRegistry holds the level of a KlikitLogger and the per name level overrides
+// shared by every named logger derived from it.
+//
+// logrus drops entries below the level of the logrus logger, so the level of the
+// underlying logrus logger is kept at the most verbose configured level and each
+// KlikitLogger filters by its own level before writing.
+type levelRegistry struct {
+	mu        sync.RWMutex
+	client    *logrus.Logger
+	base      logrus.Level
+	overrides map[string]logrus.Level
+}
+
+func newLevelRegistry(client *logrus.Logger) *levelRegistry {
+	return &levelRegistry{
+		client:    client,
+		base:      client.Level,
+		overrides: map[string]logrus.Level{},
+	}
+}
+
+func (lr *levelRegistry) setBase(level logrus.Level) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	lr.base = level
+	lr.syncClientLevel()
+}
+
+func (lr *levelRegistry) set(name string, level logrus.Level) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	if len(lr.overrides) == 0 {
+		// the level may have been set on the logrus logger directly
+		lr.base = lr.client.GetLevel()
+	}
+	lr.overrides[name] = level
+	lr.syncClientLevel()
+}
+
+func (lr *levelRegistry) reset(name string) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+
+	delete(lr.overrides, name)
+	lr.syncClientLevel()
+}
+
+// get returns the level of the closest configured name, "payments.stripe" falls back
+// to "payments" and then to the base level
+func (lr *levelRegistry) get(name string) logrus.Level {
+	lr.mu.RLock()
+	defer lr.mu.RUnlock()
+
+	for name != "" {
+		if level, ok := lr.overrides[name]; ok {
+			return level
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	if len(lr.overrides) == 0 {
+		return lr.client.GetLevel()
+	}
+	return lr.base
+}
+
+func (lr *levelRegistry) syncClientLevel() {
+	level := lr.base
+	for _, l := range lr.overrides {
+		if l > level {
+			level = l
+		}
+	}
+	lr.client.SetLevel(level)
+}
+
+// Named returns a named logger derived from the default logger, see KlikitLogger.Named
+func Named(name string) *KlikitLogger {
+	return Default().Named(name)
+}
+
+// With returns a logger derived from the default logger with the fields added, see KlikitLogger.With
+func With(keyValues ...interface{}) *KlikitLogger {
+	return Default().With(keyValues...)
+}
+
+// SetNamedLevel sets the level of the named loggers derived from the default logger, see KlikitLogger.SetNamedLevel
+func SetNamedLevel(name string, level logrus.Level) {
+	Default().SetNamedLevel(name, level)
+}
+
+// ResetNamedLevel removes the level set by SetNamedLevel on the default logger
+func ResetNamedLevel(name string) {
+	Default().ResetNamedLevel(name)
+}
+
+// Named returns a child logger with the name appended to the name of r, separated by a dot.
+// The child writes through the same logrus logger, so it keeps the output, formatter and hooks
+// of r, inherits its fields, slack and trace settings, even the ones set on r later, and adds a
+// "logger" field with its name.
+//
+// Example:
+//
+//	paymentLogger := logger.Named("payments").With("brand_id", brandID)
+//	paymentLogger.Info("payment received")
+func (r *KlikitLogger) Named(name string) *KlikitLogger {
+	child := r.derive()
+	if r.name != "" {
+		name = r.name + "." + name
+	}
+	child.name = name
+	return child
+}
+
+// With returns a child logger of r which adds the key value pairs as fields to every log
+func (r *KlikitLogger) With(keyValues ...interface{}) *KlikitLogger {
+	child := r.derive()
+	for i := 0; i < len(keyValues); i += 2 {
+		key := fmt.Sprint(keyValues[i])
+		var value interface{}
+		if i+1 < len(keyValues) {
+			value = keyValues[i+1]
+		}
+		child.fields[key] = value
+	}
+	return child
+}
+
+// Name returns the dotted name of the logger, empty for a root logger
+func (r *KlikitLogger) Name() string {
+	return r.name
+}
+
+// SetNamedLevel sets the level of the logger with the name and of its descendants at runtime,
+// e.g. SetNamedLevel("payments", logrus.DebugLevel) enables debug logs of "payments.stripe" too.
+// The levels are shared by all loggers derived from the same root logger.
+func (r *KlikitLogger) SetNamedLevel(name string, level logrus.Level) {
+	r.levels.set(name, level)
+}
+
+// ResetNamedLevel removes the level set by SetNamedLevel, the logger falls back to its parent level
+func (r *KlikitLogger) ResetNamedLevel(name string) {
+	r.levels.reset(name)
+}
+
+// SetSlackServiceName overrides the service name in slack alerts sent by the logger and its children
+func (r *KlikitLogger) SetSlackServiceName(service string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.serviceName = service
+}
+
+func (r *KlikitLogger) derive() *KlikitLogger {
+	fields := make(logrus.Fields, len(r.fields))
+	for k, v := range r.fields {
+		fields[k] = v
+	}
+
+	return &KlikitLogger{
+		client: r.client,
+		levels: r.levels,
+		parent: r,
+		name:   r.name,
+		fields: fields,
+	}
+}
+
+func (r *KlikitLogger) level() logrus.Level {
+	return r.levels.get(r.name)
+}
+
+// entry returns a logrus entry with the name and fields of the logger
+func (r *KlikitLogger) entry() *logrus.Entry {
+	entry := r.client.WithFields(r.fields)
+	if r.name != "" {
+		entry.Data["logger"] = r.name
+	}
+	return entry
+}
+
+// slackTarget returns the closest slack client and service name set on the logger or its parents
+func (r *KlikitLogger) slackTarget() (*slackit.SlackitClient, string) {
+	var client *slackit.SlackitClient
+	var service string
+	for l := r; l != nil && (client == nil || service == ""); l = l.parent {
+		l.mu.RLock()
+		if client == nil {
+			client = l.slackitClient
+		}
+		if service == "" {
+			service = l.serviceName
+		}
+		l.mu.RUnlock()
+	}
+	return client, service
+}
+
+func (r *KlikitLogger) slackFields() map[string]interface{} {
+	if len(r.fields) == 0 {
+		return nil
+	}
+	return r.fields
+}
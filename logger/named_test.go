@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeLines(t *testing.T, out *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &m))
+		lines = append(lines, m)
+	}
+	out.Reset()
+	return lines
+}
+
+func TestKlikitLogger_Named(t *testing.T) {
+	var out bytes.Buffer
+	root, _ := newTestLogger(t, &out)
+	root.SetLogLevel(logrus.InfoLevel)
+
+	payments := root.Named("payments").With("brand_id", 12)
+	stripe := payments.Named("stripe").With("branch_id", 7, "odd")
+
+	assert.Equal(t, "payments", payments.Name())
+	assert.Equal(t, "payments.stripe", stripe.Name())
+
+	stripe.Info("charged")
+	lines := decodeLines(t, &out)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "payments.stripe", lines[0]["logger"])
+	assert.Equal(t, float64(12), lines[0]["brand_id"])
+	assert.Equal(t, float64(7), lines[0]["branch_id"])
+	assert.Contains(t, lines[0], "odd")
+
+	payments.Info("parent")
+	lines = decodeLines(t, &out)
+	require.Len(t, lines, 1)
+	assert.NotContains(t, lines[0], "branch_id")
+
+	root.Info("root")
+	lines = decodeLines(t, &out)
+	require.Len(t, lines, 1)
+	assert.NotContains(t, lines[0], "logger")
+	assert.NotContains(t, lines[0], "brand_id")
+}
+
+func TestKlikitLogger_SetNamedLevel(t *testing.T) {
+	var out bytes.Buffer
+	root, _ := newTestLogger(t, &out)
+	root.SetLogLevel(logrus.InfoLevel)
+	payments := root.Named("payments")
+	stripe := payments.Named("stripe")
+	orders := root.Named("orders")
+
+	root.SetNamedLevel("payments", logrus.DebugLevel)
+	stripe.Debug("stripe debug")
+	orders.Debug("orders debug")
+	root.Debug("root debug")
+	lines := decodeLines(t, &out)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "stripe debug", lines[0]["msg"])
+
+	stripe.SetLogLevel(logrus.ErrorLevel)
+	stripe.Warn("stripe warn")
+	payments.Warn("payments warn")
+	lines = decodeLines(t, &out)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "payments warn", lines[0]["msg"])
+
+	root.ResetNamedLevel("payments")
+	root.ResetNamedLevel("payments.stripe")
+	payments.Debug("payments debug")
+	stripe.Warn("stripe warn")
+	lines = decodeLines(t, &out)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "stripe warn", lines[0]["msg"])
+	assert.Equal(t, logrus.InfoLevel, root.Logrus().Level)
+}
+
+func TestKlikitLogger_Named_slack(t *testing.T) {
+	var out bytes.Buffer
+	root, rootSlack := newTestLogger(t, &out)
+	paymentsSlack := newSlackRecorder(t)
+
+	payments := root.Named("payments").With("brand_id", 12)
+	payments.SetSlackLogger(paymentsSlack.server.URL, "payments-service")
+	orders := root.Named("orders")
+	orders.SetSlackServiceName("orders-service")
+
+	payments.Named("stripe").Error("charge failed")
+	orders.Error("order failed")
+
+	paymentBodies, rootBodies := paymentsSlack.take(), rootSlack.take()
+	require.Len(t, paymentBodies, 1)
+	require.Len(t, rootBodies, 1)
+	assert.Contains(t, paymentBodies[0], "payments-service")
+	assert.Contains(t, paymentBodies[0], `\"logger\": \"payments.stripe\"`)
+	assert.Contains(t, paymentBodies[0], `\"brand_id\": 12`)
+	assert.Contains(t, rootBodies[0], "orders-service")
+}
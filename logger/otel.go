@@ -0,0 +1,113 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SetTraceURLTemplate sets the trace viewer url used for the Trace link in slack alerts
+// of the default logger, see KlikitLogger.SetTraceURLTemplate
+func SetTraceURLTemplate(template string) {
+	Default().SetTraceURLTemplate(template)
+}
+
+// SetRecordErrorsOnSpan enables recording Error level logs of the default logger on the
+// active span, see KlikitLogger.SetRecordErrorsOnSpan
+func SetRecordErrorsOnSpan(enabled bool) {
+	Default().SetRecordErrorsOnSpan(enabled)
+}
+
+// SetTraceURLTemplate sets the trace viewer url used for the Trace link in slack alerts
+// of the logger and its children. {trace_id} and {span_id} in the template are replaced
+// by the ids of the active span.
+//
+// Example: https://grafana.example.com/explore?traceId={trace_id}
+func (r *KlikitLogger) SetTraceURLTemplate(template string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.traceURLTemplate = template
+}
+
+// SetRecordErrorsOnSpan enables recording Error level logs of the logger and its children
+// written with a context as events on the active span and marking the span status as error
+func (r *KlikitLogger) SetRecordErrorsOnSpan(enabled bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recordErrorsOnSpan = &enabled
+}
+
+// traceSettings returns the closest trace url template and span error recording set on the
+// logger or its parents
+func (r *KlikitLogger) traceSettings() (string, bool) {
+	var template string
+	var record *bool
+	for l := r; l != nil && (template == "" || record == nil); l = l.parent {
+		l.mu.RLock()
+		if template == "" {
+			template = l.traceURLTemplate
+		}
+		if record == nil {
+			record = l.recordErrorsOnSpan
+		}
+		l.mu.RUnlock()
+	}
+	return template, record != nil && *record
+}
+
+// withTraceFields stamps trace_id and span_id of the span active in ctx on the entry
+func withTraceFields(ctx context.Context, entry *logrus.Entry) (string, string) {
+	spanCtx := trace.SpanContextFromContext(ctx)
+	if !spanCtx.IsValid() {
+		return "", ""
+	}
+
+	traceID := spanCtx.TraceID().String()
+	spanID := spanCtx.SpanID().String()
+	entry.Data["trace_id"] = traceID
+	entry.Data["span_id"] = spanID
+	return traceID, spanID
+}
+
+// recordSpanError adds the error log as an event on the span active in ctx
+// and sets the span status to error, when enabled by SetRecordErrorsOnSpan
+func (r *KlikitLogger) recordSpanError(ctx context.Context, file string, args ...interface{}) {
+	if _, record := r.traceSettings(); !record {
+		return
+	}
+
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	msg := fmt.Sprint(args...)
+	attrs := []attribute.KeyValue{
+		attribute.String("log.severity", "error"),
+		attribute.String("log.message", msg),
+		attribute.String("code.filepath", file),
+	}
+
+	err, _ := firstError(args...)
+	if err != nil {
+		span.RecordError(err, trace.WithAttributes(attrs...))
+	} else {
+		span.AddEvent("log", trace.WithAttributes(attrs...))
+	}
+	span.SetStatus(codes.Error, msg)
+}
+
+// traceURL builds the trace viewer link for the slack alert, empty when not configured
+func (r *KlikitLogger) traceURL(traceID, spanID string) string {
+	template, _ := r.traceSettings()
+	if template == "" || traceID == "" {
+		return ""
+	}
+
+	return strings.NewReplacer("{trace_id}", traceID, "{span_id}", spanID).Replace(template)
+}
@@ -0,0 +1,150 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startSpan starts a recording span whose ended spans are kept by the returned recorder
+func startSpan(t *testing.T) (context.Context, trace.Span, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	ctx, span := provider.Tracer("logger").Start(context.Background(), "request")
+	return ctx, span, recorder
+}
+
+func eventAttributes(event sdktrace.Event) map[attribute.Key]string {
+	attrs := make(map[attribute.Key]string, len(event.Attributes))
+	for _, attr := range event.Attributes {
+		attrs[attr.Key] = attr.Value.Emit()
+	}
+	return attrs
+}
+
+func TestKlikitLogger_SetRecordErrorsOnSpan(t *testing.T) {
+	var out bytes.Buffer
+	l, _ := newTestLogger(t, &out)
+
+	// disabled by default
+	ctx, span, recorder := startSpan(t)
+	l.ErrorCtx(ctx, "not recorded")
+	span.End()
+	require.Len(t, recorder.Ended(), 1)
+	assert.Empty(t, recorder.Ended()[0].Events())
+	assert.Equal(t, codes.Unset, recorder.Ended()[0].Status().Code)
+
+	l.SetRecordErrorsOnSpan(true)
+
+	// an error argument is recorded as an exception event
+	ctx, span, recorder = startSpan(t)
+	l.ErrorCtx(ctx, errors.New("payment declined"))
+	l.WarnCtx(ctx, "warnings are not recorded")
+	span.End()
+	require.Len(t, recorder.Ended(), 1)
+	ended := recorder.Ended()[0]
+	require.Len(t, ended.Events(), 1)
+	event := ended.Events()[0]
+	assert.Equal(t, "exception", event.Name)
+	attrs := eventAttributes(event)
+	assert.Equal(t, "payment declined", attrs["exception.message"])
+	assert.Equal(t, "error", attrs["log.severity"])
+	assert.Contains(t, attrs["code.filepath"], "otel_test.go")
+	assert.Equal(t, codes.Error, ended.Status().Code)
+	assert.Equal(t, "payment declined", ended.Status().Description)
+
+	// a message without error is recorded as a log event
+	ctx, span, recorder = startSpan(t)
+	l.ErrorCtx(ctx, "order 12 failed")
+	span.End()
+	ended = recorder.Ended()[0]
+	require.Len(t, ended.Events(), 1)
+	assert.Equal(t, "log", ended.Events()[0].Name)
+	assert.Equal(t, "order 12 failed", eventAttributes(ended.Events()[0])["log.message"])
+	assert.Equal(t, codes.Error, ended.Status().Code)
+
+	// nothing to record on without a span
+	assert.NotPanics(t, func() { l.ErrorCtx(context.Background(), "no span") })
+}
+
+func TestWithTraceFields(t *testing.T) {
+	entry := logrus.NewEntry(logrus.New())
+	traceID, spanID := withTraceFields(context.Background(), entry)
+	assert.Empty(t, traceID)
+	assert.Empty(t, spanID)
+	assert.NotContains(t, entry.Data, "trace_id")
+
+	ctx, span, _ := startSpan(t)
+	defer span.End()
+	traceID, spanID = withTraceFields(ctx, entry)
+	assert.Equal(t, span.SpanContext().TraceID().String(), traceID)
+	assert.Equal(t, span.SpanContext().SpanID().String(), spanID)
+	assert.Equal(t, traceID, entry.Data["trace_id"])
+	assert.Equal(t, spanID, entry.Data["span_id"])
+}
+
+func TestKlikitLogger_trace_settings_of_children(t *testing.T) {
+	l := NewLoggerClient()
+	l.Logrus().SetOutput(io.Discard)
+
+	// children are usually created at package level, before main configures the logger
+	orders := l.Named("orders")
+	payments := l.Named("payments")
+	payments.SetRecordErrorsOnSpan(false)
+	l.SetTraceURLTemplate("https://traces.example.com/{trace_id}/{span_id}")
+	l.SetRecordErrorsOnSpan(true)
+
+	fulfilment := orders.Named("fulfilment")
+	assert.Equal(t, "https://traces.example.com/t1/s1", fulfilment.traceURL("t1", "s1"))
+	assert.Equal(t, "https://traces.example.com/t1/s1", payments.traceURL("t1", "s1"))
+
+	ctx, span, recorder := startSpan(t)
+	fulfilment.ErrorCtx(ctx, "shipment failed")
+	payments.ErrorCtx(ctx, "charge failed")
+	span.End()
+	require.Len(t, recorder.Ended(), 1)
+	require.Len(t, recorder.Ended()[0].Events(), 1)
+	assert.Equal(t, "shipment failed", eventAttributes(recorder.Ended()[0].Events()[0])["log.message"])
+}
+
+func TestKlikitLogger_settings_while_logging(t *testing.T) {
+	l := NewLoggerClient()
+	l.Logrus().SetOutput(io.Discard)
+	orders := l.Named("orders")
+	ctx, span, _ := startSpan(t)
+	defer span.End()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			l.SetTraceURLTemplate(fmt.Sprint("https://traces.example.com/", i, "/{trace_id}"))
+			l.SetRecordErrorsOnSpan(i%2 == 0)
+			l.SetSlackServiceName(fmt.Sprint("orders-", i))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			orders.ErrorCtx(ctx, "order failed")
+			orders.traceURL("t1", "s1")
+		}
+	}()
+	wg.Wait()
+}
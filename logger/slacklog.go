@@ -7,29 +7,39 @@ import (
 	"github.com/mostakim64/golang-utils/slackit"
 )
 
-var slackitClient *slackit.SlackitClient
-var serviceName string
-
+// SetSlackLogger sets the slack webhook and service name of the default logger
 func SetSlackLogger(webhookUrl, service string) {
+	Default().SetSlackLogger(webhookUrl, service)
+}
+
+// SetSlackLogger sets the slack webhook where Error, Fatal and Panic logs of the KlikitLogger
+// are sent as alerts from the service
+func (r *KlikitLogger) SetSlackLogger(webhookUrl, service string) {
 	client := slackit.NewSlackitClient(webhookUrl)
-	slackitClient = &client
-	serviceName = service
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.slackitClient = &client
+	r.serviceName = service
 }
 
-func send(msg string) {
-	clientReq := slackit.ClientRequest{
-		Header:      "Alert",
-		ServiceName: serviceName,
-		Summary:     "Some error occurred TEST",
-		Details:     msg,
-		Status:      slackit.Alert,
-	}
+// ProcessAndSend sends the log to slack through the default logger
+func ProcessAndSend(slackLogReq SlacklogRequest, status int, logType string) error {
+	return Default().ProcessAndSend(slackLogReq, status, logType)
+}
 
-	_ = slackitClient.Send(clientReq)
+// ProcessAndSendWithMeta sends the log with metadata to slack through the default logger
+func ProcessAndSendWithMeta(slackLogReq SlacklogRequest, metaData interface{}, status int, logType string) error {
+	return Default().ProcessAndSendWithMeta(slackLogReq, metaData, status, logType)
 }
 
-func ProcessAndSend(slackLogReq SlacklogRequest, status int, logType string) error {
+// ProcessAndSendWithApiError sends the api error log to slack through the default logger
+func ProcessAndSendWithApiError(slackLogReq SlacklogRequestWithApiError, metaData interface{}, status int, logType string) error {
+	return Default().ProcessAndSendWithApiError(slackLogReq, metaData, status, logType)
+}
 
+func (r *KlikitLogger) ProcessAndSend(slackLogReq SlacklogRequest, status int, logType string) error {
+
+	slackitClient, serviceName := r.slackTarget()
 	if slackitClient != nil {
 		msg, err := json.MarshalIndent(&slackLogReq, "", "\t")
 		if err != nil {
@@ -42,6 +52,7 @@ func ProcessAndSend(slackLogReq SlacklogRequest, status int, logType string) err
 				Summary:     logType + " Log from " + serviceName,
 				Details:     string(msg),
 				Status:      status,
+				TraceUrl:    r.traceURL(slackLogReq.TraceID, slackLogReq.SpanID),
 			}
 			err = slackitClient.Send(clientReq)
 			if err != nil {
@@ -53,8 +64,9 @@ func ProcessAndSend(slackLogReq SlacklogRequest, status int, logType string) err
 	return nil
 }
 
-func ProcessAndSendWithMeta(slackLogReq SlacklogRequest, metaData interface{}, status int, logType string) error {
+func (r *KlikitLogger) ProcessAndSendWithMeta(slackLogReq SlacklogRequest, metaData interface{}, status int, logType string) error {
 
+	slackitClient, serviceName := r.slackTarget()
 	if slackitClient != nil {
 
 		metaJson, err := json.MarshalIndent(metaData, "", "  ")
@@ -74,6 +86,7 @@ func ProcessAndSendWithMeta(slackLogReq SlacklogRequest, metaData interface{}, s
 				Metadata:    string(metaJson),
 				Details:     string(msg),
 				Status:      status,
+				TraceUrl:    r.traceURL(slackLogReq.TraceID, slackLogReq.SpanID),
 			}
 			err = slackitClient.Send(clientReq)
 			if err != nil {
@@ -85,8 +98,9 @@ func ProcessAndSendWithMeta(slackLogReq SlacklogRequest, metaData interface{}, s
 	return nil
 }
 
-func ProcessAndSendWithApiError(slackLogReq SlacklogRequestWithApiError, metaData interface{}, status int, logType string) error {
+func (r *KlikitLogger) ProcessAndSendWithApiError(slackLogReq SlacklogRequestWithApiError, metaData interface{}, status int, logType string) error {
 
+	slackitClient, serviceName := r.slackTarget()
 	if slackitClient != nil {
 
 		metaJson, err := json.MarshalIndent(metaData, "", "  ")
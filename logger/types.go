@@ -2,19 +2,23 @@ package logger
 
 import (
 	"net/http"
+	"sync"
 
 	"github.com/mostakim64/golang-utils/slackit"
 	"github.com/sirupsen/logrus"
 )
 
-// fields wraps logrus.Fields, which is a map[string]interface{}
-type fields logrus.Fields
-
 type SlacklogRequest struct {
-	Message string   `json:"message"`
-	File    string   `json:"file"`
-	Level   string   `json:"level"`
-	Trace   []string `json:"trace,omitempty"`
+	Message    string                 `json:"message"`
+	File       string                 `json:"file"`
+	Level      string                 `json:"level"`
+	Logger     string                 `json:"logger,omitempty"`
+	Fields     map[string]interface{} `json:"fields,omitempty"`
+	Trace      []string               `json:"trace,omitempty"`
+	ErrorChain []string               `json:"error.chain,omitempty"`
+	ErrorStack []string               `json:"error.stack,omitempty"`
+	TraceID    string                 `json:"trace_id,omitempty"`
+	SpanID     string                 `json:"span_id,omitempty"`
 }
 
 type SlacklogRequestWithApiError struct {
@@ -26,6 +30,18 @@ type SlacklogRequestWithApiError struct {
 
 type KlikitLogger struct {
 	client *logrus.Logger
+	levels *levelRegistry
+	parent *KlikitLogger
+	name   string
+	fields logrus.Fields
+
+	// mu guards the settings below, which are looked up through the parents on every log
+	mu               sync.RWMutex
+	slackitClient    *slackit.SlackitClient
+	serviceName      string
+	traceURLTemplate string
+	// recordErrorsOnSpan is nil unless set on this logger, which then follows its parents
+	recordErrorsOnSpan *bool
 }
 
 type RequestResponseMap struct {
@@ -0,0 +1,229 @@
+package echo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mostakim64/golang-utils/logger"
+	"github.com/mostakim64/golang-utils/redisutil"
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+)
+
+const (
+	defaultIdempotencyHeader    = "Idempotency-Key"
+	defaultIdempotencyKeyPrefix = "idempotency:"
+	defaultIdempotencyTTL       = 24 * time.Hour
+	defaultIdempotencyLockTTL   = time.Minute
+	// idempotencyStoreTimeout bounds storing the response and unlocking, which outlive the request
+	idempotencyStoreTimeout = 5 * time.Second
+	// IdempotentReplayedHeader is set on the responses replayed from a previous request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// IdempotencyConfig configures Idempotency
+type IdempotencyConfig struct {
+	// Header carries the key chosen by the client, "Idempotency-Key" by default
+	Header string
+	// Required rejects the requests without the header with 400, otherwise they pass through
+	Required bool
+	// Scope separates the keys of different clients, e.g. by user id, so a key can't replay
+	// the response of someone else
+	Scope func(c echo.Context) string
+	// TTL is how long the response is replayed, 24 hours by default
+	TTL time.Duration
+	// LockTTL is how long the key stays locked if the pod dies while handling the request,
+	// the lock is renewed while the request is in progress, 1 minute by default
+	LockTTL time.Duration
+	// KeyPrefix is prepended to the key after the Redis Prefix, "idempotency:" by default
+	KeyPrefix string
+}
+
+// idempotentResponse is the stored first response of a key
+type idempotentResponse struct {
+	// RequestHash identifies the method, path and body of the request
+	RequestHash string      `json:"request_hash"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
+
+/*
+Idempotency, a echo middleware replaying the response of the first request for the retries
+with the same Idempotency-Key header. While the first request is in progress, and when the
+key is reused for a different request, 409 is returned. Server errors are not stored so
+the request can be retried.
+
+Example:
+
+	g.POST("/orders", c.CreateOrder, m.Idempotency(redis, m.IdempotencyConfig{
+		Required: true,
+		Scope: func(c echo.Context) string {
+			return c.Get("user_id").(string)
+		},
+	}))
+*/
+func Idempotency(r *redisutil.Redis, config IdempotencyConfig) echo.MiddlewareFunc {
+	if config.Header == "" {
+		config.Header = defaultIdempotencyHeader
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaultIdempotencyTTL
+	}
+	if config.LockTTL <= 0 {
+		config.LockTTL = defaultIdempotencyLockTTL
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = defaultIdempotencyKeyPrefix
+	}
+
+	responses := redisutil.NewCache[idempotentResponse](r)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			idempotencyKey := c.Request().Header.Get(config.Header)
+			if idempotencyKey == "" {
+				if config.Required {
+					return c.JSON(http.StatusBadRequest, map[string]interface{}{"data": config.Header + " header is required"})
+				}
+				return next(c)
+			}
+
+			requestHash, err := hashRequest(c)
+			if err != nil {
+				logger.Error(err)
+				return c.JSON(http.StatusBadRequest, map[string]interface{}{"data": "failed to read request body"})
+			}
+
+			key := config.KeyPrefix
+			if config.Scope != nil {
+				key += config.Scope(c) + ":"
+			}
+			key += idempotencyKey
+
+			ctx := c.Request().Context()
+			if replayed, err := replayResponse(c, responses, key, requestHash); replayed || err != nil {
+				return err
+			}
+
+			lock, err := r.TryLock(ctx, key, config.LockTTL)
+			if errors.Is(err, errutil.ErrLockNotObtained) {
+				return c.JSON(http.StatusConflict, map[string]interface{}{"data": "a request with the same " + config.Header + " is in progress"})
+			}
+			if err != nil {
+				logger.Error(err)
+				return c.JSON(http.StatusInternalServerError, err)
+			}
+			defer func() {
+				// the request may be cancelled by now, the key must be unlocked anyway
+				ctx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+				defer cancel()
+				if err := lock.Unlock(ctx); err != nil {
+					logger.Warn("idempotency: failed to unlock ", key, ": ", err)
+				}
+			}()
+
+			// the first request may have finished between the lookup and the lock
+			if replayed, err := replayResponse(c, responses, key, requestHash); replayed || err != nil {
+				return err
+			}
+
+			recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = recorder
+			if err := next(c); err != nil {
+				// write the error response now so it can be stored
+				c.Error(err)
+			}
+
+			status := c.Response().Status
+			if status >= http.StatusInternalServerError {
+				return nil
+			}
+			response := idempotentResponse{
+				RequestHash: requestHash,
+				Status:      status,
+				Header:      c.Response().Header().Clone(),
+				Body:        recorder.body.Bytes(),
+			}
+			// the response is sent, so it is stored even if the client went away meanwhile
+			storeCtx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+			defer cancel()
+			if err := responses.Set(storeCtx, key, response, config.TTL); err != nil {
+				logger.Error("idempotency: failed to store the response of ", key, ": ", err)
+			}
+			return nil
+		}
+	}
+}
+
+// replayResponse writes the stored response of key, true means it was written
+func replayResponse(c echo.Context, responses *redisutil.Cache[idempotentResponse], key, requestHash string) (bool, error) {
+	stored, _, err := responses.Get(c.Request().Context(), key)
+	if errors.Is(err, errutil.ErrCacheMiss) {
+		return false, nil
+	}
+	if err != nil {
+		logger.Error(err)
+		return true, c.JSON(http.StatusInternalServerError, err)
+	}
+
+	if stored.RequestHash != requestHash {
+		return true, c.JSON(http.StatusConflict, map[string]interface{}{"data": "the idempotency key was used for a different request"})
+	}
+
+	header := c.Response().Header()
+	for name, values := range stored.Header {
+		header[name] = values
+	}
+	header.Set(IdempotentReplayedHeader, "true")
+	c.Response().WriteHeader(stored.Status)
+	_, err = c.Response().Write(stored.Body)
+	return true, err
+}
+
+// hashRequest hashes the method, path and body, leaving the body readable by the handler
+func hashRequest(c echo.Context) (string, error) {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return "", err
+	}
+	c.Request().Body = io.NopCloser(bytes.NewBuffer(body))
+
+	h := sha256.New()
+	h.Write([]byte(c.Request().Method + " " + c.Request().URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// responseRecorder copies the response body written to the client
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("idempotency: the response writer doesn't support hijacking")
+	}
+	return hijacker.Hijack()
+}
@@ -0,0 +1,162 @@
+package echo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/mostakim64/golang-utils/redisutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newIdempotentServer serves POST /orders behind Idempotency and counts the handled requests
+func newIdempotentServer(t *testing.T, config IdempotencyConfig, handler echo.HandlerFunc) (*echo.Echo, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	r, err := redisutil.New(redisutil.Options{Host: mr.Host(), Port: mr.Port(), Prefix: "test:"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = r.RedisClient.Close() })
+
+	e := echo.New()
+	e.POST("/orders", handler, Idempotency(r, config))
+	return e, mr
+}
+
+func postOrder(e *echo.Echo, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotency_replays_the_first_response(t *testing.T) {
+	calls := 0
+	e, mr := newIdempotentServer(t, IdempotencyConfig{}, func(c echo.Context) error {
+		calls++
+		c.Response().Header().Set("X-Order-Id", "12")
+		return c.String(http.StatusCreated, "created")
+	})
+
+	first := postOrder(e, "k1", `{"item":1}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+	assert.True(t, mr.Exists("test:idempotency:k1"))
+	assert.False(t, mr.Exists("test:lock:idempotency:k1"))
+
+	retry := postOrder(e, "k1", `{"item":1}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "created", retry.Body.String())
+	assert.Equal(t, "12", retry.Header().Get("X-Order-Id"))
+	assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 1, calls)
+
+	// a different key or no key is handled
+	assert.Equal(t, http.StatusCreated, postOrder(e, "k2", `{"item":1}`).Code)
+	assert.Equal(t, http.StatusCreated, postOrder(e, "", `{"item":1}`).Code)
+	assert.Equal(t, 3, calls)
+}
+
+func TestIdempotency_mismatched_payload(t *testing.T) {
+	e, _ := newIdempotentServer(t, IdempotencyConfig{}, func(c echo.Context) error {
+		return c.String(http.StatusCreated, "created")
+	})
+
+	assert.Equal(t, http.StatusCreated, postOrder(e, "k1", `{"item":1}`).Code)
+	assert.Equal(t, http.StatusConflict, postOrder(e, "k1", `{"item":2}`).Code)
+}
+
+func TestIdempotency_in_flight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	e, _ := newIdempotentServer(t, IdempotencyConfig{}, func(c echo.Context) error {
+		close(started)
+		<-release
+		return c.String(http.StatusCreated, "created")
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postOrder(e, "k1", `{"item":1}`) }()
+	<-started
+
+	assert.Equal(t, http.StatusConflict, postOrder(e, "k1", `{"item":1}`).Code)
+	close(release)
+	assert.Equal(t, http.StatusCreated, (<-done).Code)
+}
+
+func TestIdempotency_errors(t *testing.T) {
+	calls := 0
+	e, mr := newIdempotentServer(t, IdempotencyConfig{Required: true, TTL: time.Minute}, func(c echo.Context) error {
+		calls++
+		if calls == 1 {
+			return echo.NewHTTPError(http.StatusInternalServerError, "database is down")
+		}
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "out of stock")
+	})
+
+	assert.Equal(t, http.StatusBadRequest, postOrder(e, "", `{}`).Code)
+	assert.Equal(t, 0, calls)
+
+	// server errors are not stored, so the retry is handled
+	assert.Equal(t, http.StatusInternalServerError, postOrder(e, "k1", `{}`).Code)
+	assert.False(t, mr.Exists("test:idempotency:k1"))
+
+	// client errors are replayed
+	assert.Equal(t, http.StatusUnprocessableEntity, postOrder(e, "k1", `{}`).Code)
+	retry := postOrder(e, "k1", `{}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, retry.Code)
+	assert.Contains(t, retry.Body.String(), "out of stock")
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, time.Minute, mr.TTL("test:idempotency:k1"))
+}
+
+func TestIdempotency_scope(t *testing.T) {
+	calls := 0
+	e, mr := newIdempotentServer(t, IdempotencyConfig{Scope: func(c echo.Context) string {
+		return c.Request().Header.Get("X-User")
+	}}, func(c echo.Context) error {
+		calls++
+		return c.NoContent(http.StatusCreated)
+	})
+
+	for _, user := range []string{"a", "b", "a"} {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "k1")
+		req.Header.Set("X-User", user)
+		e.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Equal(t, 2, calls)
+	assert.True(t, mr.Exists("test:idempotency:b:k1"))
+}
+
+func TestIdempotency_cancelled_request(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	e, mr := newIdempotentServer(t, IdempotencyConfig{TTL: 90 * time.Minute}, func(c echo.Context) error {
+		calls++
+		// the client goes away while the order is created
+		cancel()
+		return c.String(http.StatusCreated, "created")
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"item":1}`)).WithContext(ctx)
+	req.Header.Set("Idempotency-Key", "k1")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	// the response is stored for the whole TTL and the key is unlocked
+	assert.Equal(t, 90*time.Minute, mr.TTL("test:idempotency:k1"))
+	assert.False(t, mr.Exists("test:lock:idempotency:k1"))
+
+	retry := postOrder(e, "k1", `{"item":1}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 1, calls)
+}
@@ -68,8 +68,21 @@ func ByRemoteIPToken(c echo.Context) (string, error) {
 // ratePerUnit := 1
 //
 // g.POST("/password/forgot", c.ForgotPassword, m.RateLimiter(m.ByEmailToken, unit, ratePerUnit))
+//
+// The limits are kept in the memory of the pod, use RateLimiterWithStore and NewRedisRateLimiterStore
+// to share them across pods.
 func RateLimiter(tokenIdentifier TokenIdentifier, unit string, ratePerUnit int) echo.MiddlewareFunc {
-	rateLimiter := configureRateLimiterStore(unit, ratePerUnit)
+	return RateLimiterWithStore(tokenIdentifier, configureRateLimiterStore(unit, ratePerUnit))
+}
+
+// RateLimiterWithStore, a echo middleware to rate limiting an endpoint with the limits kept in rateLimiter
+//
+// # Example of sharing the limits across pods through redis
+//
+// store := m.NewRedisRateLimiterStoreWithUnit(redis, "MINUTE", 1)
+//
+// g.POST("/password/forgot", c.ForgotPassword, m.RateLimiterWithStore(m.ByEmailToken, store))
+func RateLimiterWithStore(tokenIdentifier TokenIdentifier, rateLimiter middleware.RateLimiterStore) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			identifier, err := tokenIdentifier(c)
@@ -0,0 +1,213 @@
+package echo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/mostakim64/golang-utils/redisutil"
+	"github.com/redis/go-redis/v9"
+)
+
+// RateLimitAlgorithm is the algorithm used by RedisRateLimiterStore
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket allows bursts of up to Burst requests, refilled at Rate per Window
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindowLog keeps a timestamp per request and allows Rate requests in any Window, exact but uses memory per request
+	SlidingWindowLog
+	// SlidingWindowCounter approximates the sliding window from the counts of the current and previous fixed windows
+	SlidingWindowCounter
+)
+
+const defaultRateLimiterKeyPrefix = "ratelimit:"
+
+// redisNowMs sets now to the redis clock in ms, so every pod measures the windows with the
+// same clock whatever the skew of their own. Commands after TIME need redis 5 or later.
+const redisNowMs = `
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+`
+
+// tokenBucketScript refills the bucket for the time elapsed since the last request and takes a token.
+// KEYS[1] bucket, ARGV rate per ms, burst, ttl in ms
+var tokenBucketScript = redis.NewScript(redisNowMs + `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local data = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+	tokens = burst
+	ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", tostring(now))
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return allowed
+`)
+
+// slidingWindowLogScript drops the requests older than the window and logs the request if under the limit.
+// KEYS[1] log, ARGV limit, window in ms, unique member
+var slidingWindowLogScript = redis.NewScript(redisNowMs + `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now - window)
+if redis.call("ZCARD", KEYS[1]) >= limit then
+	return 0
+end
+redis.call("ZADD", KEYS[1], now, ARGV[3])
+redis.call("PEXPIRE", KEYS[1], window)
+return 1
+`)
+
+// slidingWindowCounterScript weighs the count of the previous window by its overlap with the sliding window.
+// KEYS[1] counters, ARGV limit, window in ms
+var slidingWindowCounterScript = redis.NewScript(redisNowMs + `
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local current = math.floor(now / window)
+local data = redis.call("HMGET", KEYS[1], "window", "cur", "prev")
+local stored = tonumber(data[1])
+local cur = tonumber(data[2]) or 0
+local prev = tonumber(data[3]) or 0
+if stored == nil or stored < current - 1 then
+	cur = 0
+	prev = 0
+elseif stored == current - 1 then
+	prev = cur
+	cur = 0
+end
+local weight = (window - (now - current * window)) / window
+local allowed = 0
+if prev * weight + cur < limit then
+	cur = cur + 1
+	allowed = 1
+end
+redis.call("HSET", KEYS[1], "window", current, "cur", cur, "prev", prev)
+redis.call("PEXPIRE", KEYS[1], window * 2)
+return allowed
+`)
+
+// RedisRateLimiterStoreConfig configures NewRedisRateLimiterStore
+type RedisRateLimiterStoreConfig struct {
+	Algorithm RateLimitAlgorithm
+	// Rate is the number of requests allowed per Window
+	Rate   int
+	Window time.Duration
+	// Burst is the size of the token bucket, Rate by default
+	Burst int
+	// KeyPrefix is prepended to the identifier after the Redis Prefix, "ratelimit:" by default
+	KeyPrefix string
+}
+
+// RedisRateLimiterStore is a middleware.RateLimiterStore shared by every pod through redis
+type RedisRateLimiterStore struct {
+	redis  *redisutil.Redis
+	config RedisRateLimiterStoreConfig
+}
+
+var _ middleware.RateLimiterStore = (*RedisRateLimiterStore)(nil)
+
+/*
+NewRedisRateLimiterStore returns a rate limiter store keeping the limits in redis, so they
+hold across pods and restarts. The limit of each identifier is checked and updated atomically
+by a Lua script, with the clock of redis.
+
+Example:
+
+	store := m.NewRedisRateLimiterStore(redis, m.RedisRateLimiterStoreConfig{
+		Algorithm: m.SlidingWindowCounter,
+		Rate:      1,
+		Window:    time.Minute,
+	})
+	g.POST("/password/forgot", c.ForgotPassword, m.RateLimiterWithStore(m.ByEmailToken, store))
+*/
+func NewRedisRateLimiterStore(r *redisutil.Redis, config RedisRateLimiterStoreConfig) *RedisRateLimiterStore {
+	if config.Rate <= 0 {
+		config.Rate = 1
+	}
+	if config.Window <= 0 {
+		config.Window = time.Second
+	}
+	if config.Burst <= 0 {
+		config.Burst = config.Rate
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = defaultRateLimiterKeyPrefix
+	}
+
+	return &RedisRateLimiterStore{redis: r, config: config}
+}
+
+// NewRedisRateLimiterStoreWithUnit returns a token bucket store allowing ratePerUnit requests per
+// unit, HOUR, MINUTE or SECOND, like RateLimiter
+func NewRedisRateLimiterStoreWithUnit(r *redisutil.Redis, unit string, ratePerUnit int) *RedisRateLimiterStore {
+	return NewRedisRateLimiterStore(r, RedisRateLimiterStoreConfig{
+		Algorithm: TokenBucket,
+		Rate:      ratePerUnit,
+		Window:    unitDuration(unit),
+	})
+}
+
+// Allow reports whether the request of identifier is within the limit
+func (s *RedisRateLimiterStore) Allow(identifier string) (bool, error) {
+	ctx := context.Background()
+	key := s.redis.Prefix + s.config.KeyPrefix + identifier
+	window := s.config.Window.Milliseconds()
+
+	var res *redis.Cmd
+	switch s.config.Algorithm {
+	case TokenBucket:
+		rate := float64(s.config.Rate) / float64(window)
+		ttl := int64(math.Ceil(float64(s.config.Burst) / rate))
+		res = tokenBucketScript.Run(ctx, s.redis.RedisClient, []string{key}, rate, s.config.Burst, ttl)
+	case SlidingWindowLog:
+		member, err := uniqueMember()
+		if err != nil {
+			return false, err
+		}
+		res = slidingWindowLogScript.Run(ctx, s.redis.RedisClient, []string{key}, s.config.Rate, window, member)
+	case SlidingWindowCounter:
+		res = slidingWindowCounterScript.Run(ctx, s.redis.RedisClient, []string{key}, s.config.Rate, window)
+	default:
+		return false, fmt.Errorf("unknown rate limit algorithm %d", s.config.Algorithm)
+	}
+
+	allowed, err := res.Int64()
+	if err != nil {
+		return false, err
+	}
+	return allowed == 1, nil
+}
+
+// uniqueMember keeps requests logged in the same millisecond apart
+func uniqueMember() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func unitDuration(unit string) time.Duration {
+	switch strings.ToUpper(unit) {
+	case timeUnitHour:
+		return time.Hour
+	case timeUnitMinute:
+		return time.Minute
+	default:
+		return time.Second
+	}
+}
@@ -0,0 +1,93 @@
+package echo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/mostakim64/golang-utils/redisutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStore returns a store on an in-process miniredis server whose clock is set by the returned func
+func newTestStore(t *testing.T, config RedisRateLimiterStoreConfig) (*RedisRateLimiterStore, *miniredis.Miniredis, func(time.Duration)) {
+	mr := miniredis.RunT(t)
+	r, err := redisutil.New(redisutil.Options{Host: mr.Host(), Port: mr.Port(), Prefix: "test:"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = r.RedisClient.Close() })
+
+	start := time.Unix(1700000000, 0).Truncate(time.Minute)
+	mr.SetTime(start)
+	return NewRedisRateLimiterStore(r, config), mr, func(elapsed time.Duration) { mr.SetTime(start.Add(elapsed)) }
+}
+
+func assertAllowed(t *testing.T, store *RedisRateLimiterStore, identifier string, expected ...bool) {
+	t.Helper()
+	for i, want := range expected {
+		allowed, err := store.Allow(identifier)
+		require.NoError(t, err)
+		assert.Equal(t, want, allowed, "request %d", i)
+	}
+}
+
+func TestRedisRateLimiterStore_token_bucket(t *testing.T) {
+	store, mr, setClock := newTestStore(t, RedisRateLimiterStoreConfig{Algorithm: TokenBucket, Rate: 2, Window: time.Minute})
+
+	assertAllowed(t, store, "a", true, true, false)
+	assertAllowed(t, store, "b", true)
+	assert.True(t, mr.Exists("test:ratelimit:a"))
+
+	setClock(30 * time.Second)
+	assertAllowed(t, store, "a", true, false)
+
+	setClock(5 * time.Minute)
+	assertAllowed(t, store, "a", true, true, false)
+}
+
+func TestRedisRateLimiterStore_sliding_window_log(t *testing.T) {
+	store, _, setClock := newTestStore(t, RedisRateLimiterStoreConfig{Algorithm: SlidingWindowLog, Rate: 2, Window: time.Minute})
+
+	assertAllowed(t, store, "a", true)
+	setClock(10 * time.Second)
+	assertAllowed(t, store, "a", true)
+	setClock(20 * time.Second)
+	assertAllowed(t, store, "a", false)
+
+	setClock(61 * time.Second)
+	assertAllowed(t, store, "a", true, false)
+	setClock(71 * time.Second)
+	assertAllowed(t, store, "a", true)
+}
+
+func TestRedisRateLimiterStore_sliding_window_counter(t *testing.T) {
+	store, _, setClock := newTestStore(t, RedisRateLimiterStoreConfig{Algorithm: SlidingWindowCounter, Rate: 4, Window: time.Minute})
+
+	setClock(59 * time.Second)
+	assertAllowed(t, store, "a", true, true, true, true, false)
+
+	// half of the previous window still counts
+	setClock(90 * time.Second)
+	assertAllowed(t, store, "a", true, true, false)
+
+	setClock(5 * time.Minute)
+	assertAllowed(t, store, "a", true, true, true, true, false)
+}
+
+func TestRateLimiterWithStore(t *testing.T) {
+	store, _, _ := newTestStore(t, RedisRateLimiterStoreConfig{Rate: 1, Window: time.Minute})
+	e := echo.New()
+	handler := RateLimiterWithStore(ByRemoteIPToken, store)(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	for _, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodPost, "/password/forgot", nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler(e.NewContext(req, rec)))
+		assert.Equal(t, want, rec.Code)
+	}
+}
@@ -0,0 +1,406 @@
+package echo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mostakim64/golang-utils/logger"
+	"github.com/mostakim64/golang-utils/redisutil"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultSessionCookieName = "session"
+	defaultSessionKeyPrefix  = "session:"
+	defaultSessionTTL        = 24 * time.Hour
+	sessionUserIndex         = "user:"
+	sessionContextKey        = "session"
+	sessionIDBytes           = 32
+)
+
+// ErrSessionSecretRequired is returned by NewSessionManager without a Secret
+var ErrSessionSecretRequired = errors.New("session: Secret is required")
+
+// SessionConfig configures NewSessionManager
+type SessionConfig struct {
+	// Secret signs the session cookie, at least 32 random bytes, required
+	Secret []byte
+	// CookieName is "session" by default
+	CookieName   string
+	CookiePath   string
+	CookieDomain string
+	// Secure sends the cookie over https only, should be on outside of local development
+	Secure bool
+	// SameSite is http.SameSiteLaxMode by default
+	SameSite http.SameSite
+	// TTL is how long an idle session lives, every request extends it, 24 hours by default
+	TTL time.Duration
+	// KeyPrefix is prepended to the session id after the Redis Prefix, "session:" by default
+	KeyPrefix string
+}
+
+// SessionManager keeps server side sessions in redis, see NewSessionManager
+type SessionManager struct {
+	redis  *redisutil.Redis
+	config SessionConfig
+}
+
+// Session is the session of a request, see GetSession. It is saved when the response is written.
+type Session struct {
+	id     string
+	data   sessionData
+	stored bool
+	dirty  bool
+	// replaced are the stored sessions replaced by Login, removed on save
+	replaced []replacedSession
+	destroy  bool
+}
+
+type replacedSession struct {
+	id     string
+	userID string
+}
+
+// sessionData is the stored part of a Session
+type sessionData struct {
+	UserID    string            `json:"user_id,omitempty"`
+	Values    map[string]string `json:"values,omitempty"`
+	Flashes   []string          `json:"flashes,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+/*
+NewSessionManager returns a SessionManager keeping the sessions in r. The cookie carries
+a random session id signed with config.Secret, the session itself stays in redis and
+expires after config.TTL without requests. Concurrent requests of a session overwrite
+each other's changes, the last one to finish wins, but a session destroyed meanwhile, e.g.
+by LogoutEverywhere, stays destroyed.
+
+Example:
+
+	sessions, err := m.NewSessionManager(redis, m.SessionConfig{Secret: secret, Secure: true})
+	admin := e.Group("/admin", sessions.Middleware())
+*/
+func NewSessionManager(r *redisutil.Redis, config SessionConfig) (*SessionManager, error) {
+	if len(config.Secret) == 0 {
+		return nil, ErrSessionSecretRequired
+	}
+	if config.CookieName == "" {
+		config.CookieName = defaultSessionCookieName
+	}
+	if config.CookiePath == "" {
+		config.CookiePath = "/"
+	}
+	if config.SameSite == 0 {
+		config.SameSite = http.SameSiteLaxMode
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaultSessionTTL
+	}
+	if config.KeyPrefix == "" {
+		config.KeyPrefix = defaultSessionKeyPrefix
+	}
+
+	return &SessionManager{redis: r, config: config}, nil
+}
+
+// Middleware loads the session of the request cookie, or starts a new one, and makes it
+// available through GetSession. A new session is stored once something is set in it.
+func (m *SessionManager) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			s, err := m.load(c)
+			if err != nil {
+				logger.Error(err)
+				return c.JSON(http.StatusInternalServerError, err)
+			}
+			c.Set(sessionContextKey, s)
+
+			// the session is saved before the response is written, so its cookie can be set
+			saved := false
+			save := func() {
+				if saved {
+					return
+				}
+				saved = true
+				if err := m.save(c, s); err != nil {
+					logger.Error("session: failed to save the session: ", err)
+				}
+			}
+			c.Response().Before(save)
+
+			err = next(c)
+			if !c.Response().Committed {
+				save()
+			}
+			return err
+		}
+	}
+}
+
+// GetSession returns the session of the request, nil outside of SessionManager.Middleware
+func GetSession(c echo.Context) *Session {
+	s, _ := c.Get(sessionContextKey).(*Session)
+	return s
+}
+
+/*
+Login attaches the session of the request to userID, after the user is authenticated. The
+session gets a new id so an id set by someone else before the login can't be used.
+
+Example:
+
+	if err := sessions.Login(c, user.ID); err != nil {
+		return err
+	}
+	m.GetSession(c).AddFlash("Welcome back " + user.Name)
+*/
+func (m *SessionManager) Login(c echo.Context, userID string) error {
+	s := GetSession(c)
+	if s == nil {
+		return errors.New("session: Login is called outside of the session middleware")
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return err
+	}
+	if s.stored {
+		s.replaced = append(s.replaced, replacedSession{id: s.id, userID: s.data.UserID})
+		s.stored = false
+	}
+	s.id = id
+	s.data.UserID = userID
+	s.dirty = true
+	return nil
+}
+
+// Logout destroys the session of the request
+func (m *SessionManager) Logout(c echo.Context) {
+	if s := GetSession(c); s != nil {
+		s.destroy = true
+	}
+}
+
+// LogoutEverywhere destroys every session of userID and returns their number
+func (m *SessionManager) LogoutEverywhere(ctx context.Context, userID string) (int64, error) {
+	index := m.userIndex(userID)
+	ids, err := m.redis.SMembers(ctx, index)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = m.config.KeyPrefix + id
+	}
+	var deleted *redis.IntCmd
+	err = m.redis.Pipeline(ctx, func(p *redisutil.Pipe) error {
+		if len(keys) > 0 {
+			deleted = p.Del(keys...)
+		}
+		p.Del(index)
+		return nil
+	})
+	if err != nil || deleted == nil {
+		return 0, err
+	}
+	return deleted.Val(), nil
+}
+
+// ID returns the session id, empty until the session is stored
+func (s *Session) ID() string {
+	if !s.stored && !s.dirty {
+		return ""
+	}
+	return s.id
+}
+
+// UserID returns the user of the session, empty before Login
+func (s *Session) UserID() string {
+	return s.data.UserID
+}
+
+// CreatedAt returns when the session started
+func (s *Session) CreatedAt() time.Time {
+	return s.data.CreatedAt
+}
+
+// Get returns the value of key
+func (s *Session) Get(key string) (string, bool) {
+	value, ok := s.data.Values[key]
+	return value, ok
+}
+
+// Set stores the value of key in the session
+func (s *Session) Set(key, value string) {
+	if s.data.Values == nil {
+		s.data.Values = make(map[string]string)
+	}
+	s.data.Values[key] = value
+	s.dirty = true
+}
+
+// Delete removes key from the session
+func (s *Session) Delete(key string) {
+	if _, ok := s.data.Values[key]; ok {
+		delete(s.data.Values, key)
+		s.dirty = true
+	}
+}
+
+// AddFlash adds a message shown once, on the next read of Flashes, typically after a redirect
+func (s *Session) AddFlash(message string) {
+	s.data.Flashes = append(s.data.Flashes, message)
+	s.dirty = true
+}
+
+// Flashes returns the flash messages and removes them from the session
+func (s *Session) Flashes() []string {
+	flashes := s.data.Flashes
+	if len(flashes) > 0 {
+		s.data.Flashes = nil
+		s.dirty = true
+	}
+	return flashes
+}
+
+// load returns the session of the request cookie, a new one when it is missing, invalid or expired
+func (m *SessionManager) load(c echo.Context) (*Session, error) {
+	if cookie, err := c.Cookie(m.config.CookieName); err == nil {
+		if id, ok := m.verify(cookie.Value); ok {
+			s := &Session{id: id}
+			err := m.redis.GetStructCtx(c.Request().Context(), m.config.KeyPrefix+id, &s.data)
+			if err == nil {
+				s.stored = true
+				return s, nil
+			}
+			if !errors.Is(err, redis.Nil) {
+				return nil, err
+			}
+		}
+	}
+
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	return &Session{id: id, data: sessionData{CreatedAt: time.Now()}}, nil
+}
+
+// save stores the changes of the session, or extends it, and sets the cookie
+func (m *SessionManager) save(c echo.Context, s *Session) error {
+	ctx := c.Request().Context()
+	if s.destroy {
+		if s.stored {
+			s.replaced = append(s.replaced, replacedSession{id: s.id, userID: s.data.UserID})
+		}
+		if len(s.replaced) == 0 {
+			return nil
+		}
+		m.setCookie(c, "", -1)
+		return m.redis.Pipeline(ctx, func(p *redisutil.Pipe) error {
+			m.removeReplaced(p, s)
+			return nil
+		})
+	}
+	if !s.stored && !s.dirty {
+		return nil
+	}
+
+	key := m.config.KeyPrefix + s.id
+	var stored *redis.BoolCmd
+	err := m.redis.Pipeline(ctx, func(p *redisutil.Pipe) error {
+		m.removeReplaced(p, s)
+		switch {
+		case !s.stored:
+			p.Set(key, s.data, m.config.TTL)
+		case s.dirty:
+			// a stored session is only updated if it still exists, so a session destroyed
+			// by LogoutEverywhere during the request isn't brought back
+			stored = p.SetXX(key, s.data, m.config.TTL)
+		default:
+			stored = p.Expire(key, m.config.TTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if stored != nil && !stored.Val() {
+		m.setCookie(c, "", -1)
+		return nil
+	}
+
+	m.setCookie(c, m.sign(s.id), int(m.config.TTL/time.Second))
+	if s.data.UserID == "" {
+		return nil
+	}
+	// the index outlives every session of the user, each one is extended by at most TTL
+	return m.redis.Pipeline(ctx, func(p *redisutil.Pipe) error {
+		p.SAdd(m.userIndex(s.data.UserID), s.id)
+		p.Expire(m.userIndex(s.data.UserID), m.config.TTL)
+		return nil
+	})
+}
+
+// removeReplaced queues removing the replaced sessions and their user index entries
+func (m *SessionManager) removeReplaced(p *redisutil.Pipe, s *Session) {
+	for _, replaced := range s.replaced {
+		p.Del(m.config.KeyPrefix + replaced.id)
+		if replaced.userID != "" {
+			p.SRem(m.userIndex(replaced.userID), replaced.id)
+		}
+	}
+}
+
+func (m *SessionManager) setCookie(c echo.Context, value string, maxAge int) {
+	c.SetCookie(&http.Cookie{
+		Name:     m.config.CookieName,
+		Value:    value,
+		Path:     m.config.CookiePath,
+		Domain:   m.config.CookieDomain,
+		MaxAge:   maxAge,
+		Secure:   m.config.Secure,
+		HttpOnly: true,
+		SameSite: m.config.SameSite,
+	})
+}
+
+// sign returns the cookie value of the session id
+func (m *SessionManager) sign(id string) string {
+	mac := hmac.New(sha256.New, m.config.Secret)
+	mac.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify returns the session id of a cookie value signed with the Secret
+func (m *SessionManager) verify(value string) (string, bool) {
+	id, _, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(value), []byte(m.sign(id))) {
+		return "", false
+	}
+	return id, true
+}
+
+// userIndex is the key of the set of session ids of userID
+func (m *SessionManager) userIndex(userID string) string {
+	return m.config.KeyPrefix + sessionUserIndex + userID
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, sessionIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
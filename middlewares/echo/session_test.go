@@ -0,0 +1,220 @@
+package echo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/mostakim64/golang-utils/redisutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSessionServer serves routes reading and changing the session behind SessionManager.Middleware
+func newSessionServer(t *testing.T) (*echo.Echo, *SessionManager, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	r, err := redisutil.New(redisutil.Options{Host: mr.Host(), Port: mr.Port(), Prefix: "test:"})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = r.RedisClient.Close() })
+
+	sessions, err := NewSessionManager(r, SessionConfig{Secret: []byte("0123456789abcdef0123456789abcdef"), TTL: time.Hour})
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.Use(sessions.Middleware())
+	e.GET("/get", func(c echo.Context) error {
+		value, _ := GetSession(c).Get(c.QueryParam("key"))
+		return c.String(http.StatusOK, value)
+	})
+	e.GET("/set", func(c echo.Context) error {
+		GetSession(c).Set(c.QueryParam("key"), c.QueryParam("value"))
+		return c.NoContent(http.StatusNoContent)
+	})
+	e.GET("/user", func(c echo.Context) error {
+		return c.String(http.StatusOK, GetSession(c).UserID())
+	})
+	e.GET("/login", func(c echo.Context) error {
+		if err := sessions.Login(c, c.QueryParam("user")); err != nil {
+			return err
+		}
+		GetSession(c).AddFlash("welcome")
+		return c.Redirect(http.StatusFound, "/flashes")
+	})
+	e.GET("/flashes", func(c echo.Context) error {
+		return c.String(http.StatusOK, strings.Join(GetSession(c).Flashes(), ","))
+	})
+	e.GET("/logout", func(c echo.Context) error {
+		sessions.Logout(c)
+		return c.NoContent(http.StatusNoContent)
+	})
+	return e, sessions, mr
+}
+
+// browser keeps the session cookie across requests
+type browser struct {
+	e      *echo.Echo
+	cookie *http.Cookie
+}
+
+func (b *browser) get(path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if b.cookie != nil {
+		req.AddCookie(b.cookie)
+	}
+	rec := httptest.NewRecorder()
+	b.e.ServeHTTP(rec, req)
+	for _, cookie := range rec.Result().Cookies() {
+		b.cookie = cookie
+		if cookie.MaxAge < 0 {
+			b.cookie = nil
+		}
+	}
+	return rec
+}
+
+func TestSessionManager_values(t *testing.T) {
+	e, _, mr := newSessionServer(t)
+	b := &browser{e: e}
+
+	// a session is stored once something is set in it
+	b.get("/get?key=lang")
+	assert.Nil(t, b.cookie)
+	assert.Empty(t, mr.Keys())
+
+	b.get("/set?key=lang&value=bn")
+	require.NotNil(t, b.cookie)
+	assert.True(t, b.cookie.HttpOnly)
+	assert.Equal(t, 3600, b.cookie.MaxAge)
+	assert.Equal(t, "bn", b.get("/get?key=lang").Body.String())
+
+	// another browser has its own session
+	assert.Empty(t, (&browser{e: e}).get("/get?key=lang").Body.String())
+}
+
+func TestSessionManager_sliding_expiration(t *testing.T) {
+	e, _, mr := newSessionServer(t)
+	b := &browser{e: e}
+
+	b.get("/set?key=lang&value=bn")
+	key := mr.Keys()[0]
+	assert.Equal(t, time.Hour, mr.TTL(key))
+
+	mr.FastForward(50 * time.Minute)
+	assert.Equal(t, "bn", b.get("/get?key=lang").Body.String())
+	assert.Equal(t, time.Hour, mr.TTL(key))
+
+	mr.FastForward(61 * time.Minute)
+	assert.Empty(t, b.get("/get?key=lang").Body.String())
+}
+
+func TestSessionManager_signed_cookie(t *testing.T) {
+	e, _, mr := newSessionServer(t)
+	b := &browser{e: e}
+	b.get("/set?key=lang&value=bn")
+
+	id, _, _ := strings.Cut(b.cookie.Value, ".")
+	assert.True(t, mr.Exists("test:session:"+id))
+
+	for _, value := range []string{id, id + ".forged", "unknown.value"} {
+		forged := &browser{e: e, cookie: &http.Cookie{Name: "session", Value: value}}
+		assert.Empty(t, forged.get("/get?key=lang").Body.String(), value)
+	}
+}
+
+func TestSessionManager_login_and_flashes(t *testing.T) {
+	e, _, mr := newSessionServer(t)
+	b := &browser{e: e}
+
+	b.get("/set?key=lang&value=bn")
+	before := b.cookie.Value
+
+	assert.Equal(t, http.StatusFound, b.get("/login?user=12").Code)
+	assert.NotEqual(t, before, b.cookie.Value, "the session id changes on login")
+	id, _, _ := strings.Cut(before, ".")
+	assert.False(t, mr.Exists("test:session:"+id))
+
+	assert.Equal(t, "12", b.get("/user").Body.String())
+	assert.Equal(t, "bn", b.get("/get?key=lang").Body.String())
+	assert.Equal(t, "welcome", b.get("/flashes").Body.String())
+	assert.Empty(t, b.get("/flashes").Body.String())
+
+	members, err := mr.SMembers("test:session:user:12")
+	require.NoError(t, err)
+	assert.Len(t, members, 1)
+}
+
+func TestSessionManager_logout(t *testing.T) {
+	e, sessions, mr := newSessionServer(t)
+	phone := &browser{e: e}
+	laptop := &browser{e: e}
+	tablet := &browser{e: e}
+	phone.get("/login?user=12")
+	laptop.get("/login?user=12")
+	tablet.get("/login?user=12")
+
+	phone.get("/logout")
+	assert.Nil(t, phone.cookie)
+	assert.Empty(t, phone.get("/user").Body.String())
+	members, err := mr.SMembers("test:session:user:12")
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+
+	deleted, err := sessions.LogoutEverywhere(context.Background(), "12")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	assert.Empty(t, laptop.get("/user").Body.String())
+	assert.Empty(t, tablet.get("/user").Body.String())
+	assert.Empty(t, mr.Keys())
+
+	deleted, err = sessions.LogoutEverywhere(context.Background(), "12")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+}
+
+func TestSessionManager_logout_everywhere_during_request(t *testing.T) {
+	e, sessions, mr := newSessionServer(t)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	e.GET("/slow", func(c echo.Context) error {
+		if key := c.QueryParam("key"); key != "" {
+			GetSession(c).Set(key, "value")
+		}
+		started <- struct{}{}
+		<-release
+		return c.NoContent(http.StatusNoContent)
+	})
+
+	// a request changing the session and one only extending it
+	for _, path := range []string{"/slow?key=cart", "/slow"} {
+		phone := &browser{e: e}
+		phone.get("/login?user=12")
+		require.NotNil(t, phone.cookie)
+
+		done := make(chan struct{})
+		go func() {
+			phone.get(path)
+			close(done)
+		}()
+		<-started
+		deleted, err := sessions.LogoutEverywhere(context.Background(), "12")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted, path)
+		release <- struct{}{}
+		<-done
+
+		// the request finishing after the logout doesn't bring the session back
+		assert.Empty(t, mr.Keys(), path)
+		assert.Nil(t, phone.cookie, path)
+		assert.Empty(t, phone.get("/user").Body.String(), path)
+	}
+}
+
+func TestNewSessionManager_secret_required(t *testing.T) {
+	_, err := NewSessionManager(nil, SessionConfig{})
+	assert.ErrorIs(t, err, ErrSessionSecretRequired)
+}
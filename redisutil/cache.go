@@ -0,0 +1,137 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache is a typed view over Redis storing values of type T with the Redis Codec under the Redis Prefix
+type Cache[T any] struct {
+	redis *Redis
+	group singleflight.Group
+}
+
+// NewCache returns a Cache of T values stored in r
+//
+// Example:
+//
+//	menuCache := redisutil.NewCache[Menu](redis)
+//	menu, ok, err := menuCache.Get(ctx, "menu:12")
+func NewCache[T any](r *Redis) *Cache[T] {
+	return &Cache[T]{redis: r}
+}
+
+// Get returns the value stored at key. On a miss ok is false and err is errutil.ErrCacheMiss.
+func (c *Cache[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var value T
+	if key == "" {
+		return value, false, errutil.ErrEmptyRedisKeyValue
+	}
+
+	b, err := c.redis.RedisClient.Get(ctx, c.redis.getKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		c.redis.observeCache(false)
+		return value, false, errutil.ErrCacheMiss
+	}
+	if err != nil {
+		return value, false, err
+	}
+
+	if err := c.redis.encoder.decode(b, &value); err != nil {
+		return value, false, err
+	}
+	c.redis.observeCache(true)
+	return value, true, nil
+}
+
+// Set stores value at key, a ttl of 0 keeps the key without expiry. The key is added to the
+// tags, if any, so it is deleted by Redis.InvalidateTags.
+func (c *Cache[T]) Set(ctx context.Context, key string, value T, ttl time.Duration, tags ...string) error {
+	if key == "" {
+		return errutil.ErrEmptyRedisKeyValue
+	}
+
+	b, err := c.redis.encoder.encode(value)
+	if err != nil {
+		return err
+	}
+
+	return c.redis.setTagged(ctx, key, b, ttl, tags)
+}
+
+// MGet returns the values of the keys which exist, missing keys are left out of the map.
+// The keys are fetched in one pipeline, so they may live in different cluster slots.
+func (c *Cache[T]) MGet(ctx context.Context, keys ...string) (map[string]T, error) {
+	values := make(map[string]T, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	cmds := make([]*redis.StringCmd, len(keys))
+	_, err := c.redis.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.Get(ctx, c.redis.getKey(key))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	for i, cmd := range cmds {
+		b, err := cmd.Bytes()
+		if errors.Is(err, redis.Nil) {
+			c.redis.observeCache(false)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var value T
+		if err := c.redis.encoder.decode(b, &value); err != nil {
+			return nil, err
+		}
+		values[keys[i]] = value
+		c.redis.observeCache(true)
+	}
+
+	return values, nil
+}
+
+// MSet stores all the values with the same ttl in one pipeline
+func (c *Cache[T]) MSet(ctx context.Context, values map[string]T, ttl time.Duration) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	encoded := make(map[string][]byte, len(values))
+	for key, value := range values {
+		if key == "" {
+			return errutil.ErrEmptyRedisKeyValue
+		}
+		b, err := c.redis.encoder.encode(value)
+		if err != nil {
+			return err
+		}
+		encoded[c.redis.getKey(key)] = b
+	}
+
+	_, err := c.redis.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, b := range encoded {
+			pipe.Set(ctx, key, b, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// Del removes the keys from the cache
+func (c *Cache[T]) Del(ctx context.Context, keys ...string) error {
+	return c.redis.DelCtx(ctx, keys...)
+}
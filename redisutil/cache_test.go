@@ -0,0 +1,69 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_Get(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	cache := NewCache[menu](r)
+
+	require.NoError(t, cache.Set(ctx, "menu:1", menu{ID: 1, Name: "lunch"}, time.Minute))
+	assert.True(t, mr.Exists("test:menu:1"))
+	assert.Equal(t, time.Minute, mr.TTL("test:menu:1"))
+
+	value, ok, err := cache.Get(ctx, "menu:1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, menu{ID: 1, Name: "lunch"}, value)
+
+	value, ok, err = cache.Get(ctx, "menu:2")
+	assert.ErrorIs(t, err, errutil.ErrCacheMiss)
+	assert.False(t, ok)
+	assert.Zero(t, value)
+
+	_, _, err = cache.Get(ctx, "")
+	assert.ErrorIs(t, err, errutil.ErrEmptyRedisKeyValue)
+}
+
+func TestCache_Get_scalar(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	cache := NewCache[int](r)
+
+	require.NoError(t, cache.Set(ctx, "count", 0, 0))
+	value, ok, err := cache.Get(ctx, "count")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 0, value)
+}
+
+func TestCache_MGet(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	cache := NewCache[menu](r)
+
+	require.NoError(t, cache.MSet(ctx, map[string]menu{
+		"menu:1": {ID: 1},
+		"menu:2": {ID: 2},
+	}, time.Minute))
+
+	values, err := cache.MGet(ctx, "menu:1", "menu:3", "menu:2")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]menu{"menu:1": {ID: 1}, "menu:2": {ID: 2}}, values)
+
+	values, err = cache.MGet(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	require.NoError(t, cache.Del(ctx, "menu:1"))
+	_, ok, _ := cache.Get(ctx, "menu:1")
+	assert.False(t, ok)
+}
@@ -0,0 +1,144 @@
+package redisutil
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec serializes the values stored by Set, SetStruct, Cache and the other value helpers
+type Codec interface {
+	// ID identifies the codec in the header byte of the stored values, 1 to 15.
+	// 1 to 4 are taken by the built-in codecs.
+	ID() byte
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	// JSONCodec encodes values with encoding/json
+	JSONCodec Codec = jsonCodec{}
+	// MsgpackCodec encodes values with msgpack, it is faster and more compact than json
+	MsgpackCodec Codec = msgpackCodec{}
+	// GobCodec encodes values with encoding/gob
+	GobCodec Codec = gobCodec{}
+	// ProtobufCodec encodes proto.Message values, anything else fails to encode.
+	// GetOrLoad wraps the value and can't be used with it.
+	ProtobufCodec Codec = protobufCodec{}
+)
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[byte]Codec{
+		JSONCodec.ID():     JSONCodec,
+		MsgpackCodec.ID():  MsgpackCodec,
+		GobCodec.ID():      GobCodec,
+		ProtobufCodec.ID(): ProtobufCodec,
+	}
+)
+
+/*
+RegisterCodec makes a custom codec known when decoding, so values written with it stay
+readable by instances configured with another codec. It panics if the ID is out of range
+or already taken, call it from init.
+*/
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	id := c.ID()
+	if err := checkCodecID(id); err != nil {
+		panic(err.Error())
+	}
+	if _, ok := codecs[id]; ok {
+		panic(fmt.Sprintf("redisutil: codec id %d already registered", id))
+	}
+	codecs[id] = c
+}
+
+// checkCodecID returns an error unless id fits in the header byte of the stored values
+func checkCodecID(id byte) error {
+	if id == 0 || id > maxCodecID {
+		return fmt.Errorf("redisutil: codec id %d out of range 1-%d", id, maxCodecID)
+	}
+	return nil
+}
+
+func codecByID(id byte) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	c, ok := codecs[id]
+	return c, ok
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ID() byte { return 1 }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ID() byte { return 2 }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) { return msgpack.Marshal(v) }
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error { return msgpack.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) ID() byte { return 3 }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) ID() byte { return 4 }
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("redisutil: protobuf codec can't encode %T, it is not a proto.Message", v)
+	}
+	return proto.Marshal(msg)
+}
+
+// Unmarshal accepts a proto.Message or a pointer to one, e.g. the *T of a Cache[*pb.Menu]
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	if msg, ok := v.(proto.Message); ok {
+		return proto.Unmarshal(data, msg)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() && rv.Elem().Kind() == reflect.Ptr {
+		elem := reflect.New(rv.Elem().Type().Elem())
+		if msg, ok := elem.Interface().(proto.Message); ok {
+			if err := proto.Unmarshal(data, msg); err != nil {
+				return err
+			}
+			rv.Elem().Set(elem)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("redisutil: protobuf codec can't decode into %T, it is not a proto.Message", v)
+}
@@ -0,0 +1,99 @@
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestEncoder_roundtrip(t *testing.T) {
+	value := menu{ID: 12, Name: strings.Repeat("burger ", 200)}
+	reader := encoder{codec: MsgpackCodec}
+
+	for _, codec := range []Codec{JSONCodec, MsgpackCodec, GobCodec} {
+		for _, compression := range []Compression{NoCompression, Gzip, Snappy, Zstd} {
+			t.Run(fmt.Sprintf("%T/%v", codec, compression), func(t *testing.T) {
+				writer := encoder{codec: codec, compression: compression, threshold: defaultCompressionThreshold}
+				b, err := writer.encode(value)
+				require.NoError(t, err)
+				assert.Equal(t, byte(headerFlag|codec.ID()<<codecShift|byte(compression)), b[0])
+
+				// values stay readable by an encoder configured with another codec
+				var got menu
+				require.NoError(t, reader.decode(b, &got))
+				assert.Equal(t, value, got)
+			})
+		}
+	}
+}
+
+func TestEncoder_threshold(t *testing.T) {
+	e := encoder{compression: Zstd, threshold: defaultCompressionThreshold}
+
+	b, err := e.encode(menu{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, byte(headerFlag|JSONCodec.ID()<<codecShift), b[0])
+
+	b, err = e.encode(menu{ID: 1, Name: strings.Repeat("a", defaultCompressionThreshold)})
+	require.NoError(t, err)
+	assert.Equal(t, byte(headerFlag|JSONCodec.ID()<<codecShift|byte(Zstd)), b[0])
+	assert.Less(t, len(b), defaultCompressionThreshold)
+}
+
+func TestEncoder_plain_json(t *testing.T) {
+	b, err := encoder{}.encode(menu{ID: 1, Name: "burger"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":1,"name":"burger"}`, string(b))
+
+	var got menu
+	require.NoError(t, encoder{codec: GobCodec, compression: Gzip}.decode(b, &got))
+	assert.Equal(t, menu{ID: 1, Name: "burger"}, got)
+}
+
+func TestEncoder_unknown_codec(t *testing.T) {
+	var got menu
+	err := encoder{}.decode([]byte{headerFlag | 9<<codecShift, '{', '}'}, &got)
+	assert.ErrorContains(t, err, "unknown codec id 9")
+}
+
+func TestProtobufCodec(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	r.encoder = encoder{codec: ProtobufCodec}
+
+	c := NewCache[*wrapperspb.StringValue](r)
+	require.NoError(t, c.Set(ctx, "name", wrapperspb.String("burger"), time.Minute))
+
+	value, ok, err := c.Get(ctx, "name")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "burger", value.GetValue())
+
+	assert.Error(t, r.SetStructCtx(ctx, "menu", menu{ID: 1}, 60))
+}
+
+func TestRedis_codec(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	r.encoder = Options{Codec: MsgpackCodec, Compression: Snappy, CompressionThreshold: 1}.encoder()
+
+	require.NoError(t, r.SetCtx(ctx, "menu:1", menu{ID: 1, Name: "burger"}, 60))
+	raw, err := mr.Get("test:menu:1")
+	require.NoError(t, err)
+	assert.Equal(t, byte(headerFlag|MsgpackCodec.ID()<<codecShift|byte(Snappy)), raw[0])
+
+	var got menu
+	require.NoError(t, r.GetStructCtx(ctx, "menu:1", &got))
+	assert.Equal(t, menu{ID: 1, Name: "burger"}, got)
+
+	// values written before switching codecs
+	require.NoError(t, mr.Set("test:menu:2", `{"id":2,"name":"pizza"}`))
+	require.NoError(t, r.GetStructCtx(ctx, "menu:2", &got))
+	assert.Equal(t, menu{ID: 2, Name: "pizza"}, got)
+}
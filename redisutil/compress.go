@@ -0,0 +1,93 @@
+package redisutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the algorithm used to compress the encoded values
+type Compression byte
+
+const (
+	NoCompression Compression = iota
+	Gzip
+	Snappy
+	Zstd
+)
+
+// defaultCompressionThreshold is the encoded size from which values are compressed
+const defaultCompressionThreshold = 1024
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+)
+
+func initZstd() {
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+}
+
+func (c Compression) String() string {
+	switch c {
+	case NoCompression:
+		return "none"
+	case Gzip:
+		return "gzip"
+	case Snappy:
+		return "snappy"
+	case Zstd:
+		return "zstd"
+	}
+	return fmt.Sprintf("Compression(%d)", byte(c))
+}
+
+func (c Compression) compress(data []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return data, nil
+	case Gzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Snappy:
+		return snappy.Encode(nil, data), nil
+	case Zstd:
+		zstdOnce.Do(initZstd)
+		return zstdEncoder.EncodeAll(data, nil), nil
+	}
+	return nil, fmt.Errorf("redisutil: unknown compression %v", c)
+}
+
+func (c Compression) decompress(data []byte) ([]byte, error) {
+	switch c {
+	case NoCompression:
+		return data, nil
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case Snappy:
+		return snappy.Decode(nil, data)
+	case Zstd:
+		zstdOnce.Do(initZstd)
+		return zstdDecoder.DecodeAll(data, nil)
+	}
+	return nil, fmt.Errorf("redisutil: unknown compression %v", c)
+}
@@ -0,0 +1,174 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// rollingBuckets is the number of buckets of a rolling window, its precision is window/rollingBuckets
+const rollingBuckets = 60
+
+// incrWithTTLScript adds to the counter and sets the ttl when the counter has none, so a
+// counter created by the increment always expires.
+// KEYS[1] counter, ARGV increment, ttl in ms or 0 for no expiry
+var incrWithTTLScript = redis.NewScript(`
+local value = redis.call("INCRBY", KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call("PTTL", KEYS[1]) == -1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return value
+`)
+
+// quotaScript adds to the counter unless it would go over the limit.
+// KEYS[1] counter, ARGV increment, limit, ttl in ms or 0 for no expiry. Returns allowed, used, ttl in ms.
+var quotaScript = redis.NewScript(`
+local n = tonumber(ARGV[1])
+local used = tonumber(redis.call("GET", KEYS[1]) or "0")
+if used + n > tonumber(ARGV[2]) then
+	return {0, used, redis.call("PTTL", KEYS[1])}
+end
+used = redis.call("INCRBY", KEYS[1], n)
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl == -1 and tonumber(ARGV[3]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[3])
+	ttl = tonumber(ARGV[3])
+end
+return {1, used, ttl}
+`)
+
+// rollingWindowScript keeps a counter per bucket of the window in a hash, drops the buckets
+// older than the window and returns the sum of the others.
+// KEYS[1] buckets, ARGV increment, now in ms, window in ms, bucket size in ms
+var rollingWindowScript = redis.NewScript(`
+local n = tonumber(ARGV[1])
+local now = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+local size = tonumber(ARGV[4])
+local current = math.floor(now / size)
+local oldest = math.floor((now - window) / size) + 1
+if n ~= 0 then
+	redis.call("HINCRBY", KEYS[1], current, n)
+	redis.call("PEXPIRE", KEYS[1], window)
+end
+local total = 0
+local data = redis.call("HGETALL", KEYS[1])
+for i = 1, #data, 2 do
+	local bucket = tonumber(data[i])
+	if bucket < oldest then
+		redis.call("HDEL", KEYS[1], data[i])
+	else
+		total = total + tonumber(data[i + 1])
+	end
+end
+return total
+`)
+
+// Quota is the state of a quota after ConsumeQuota
+type Quota struct {
+	// Allowed reports whether the amount was consumed
+	Allowed bool
+	// Used is the amount consumed in the current period
+	Used      int64
+	Remaining int64
+	// ResetIn is the time until the period ends and the quota is available again
+	ResetIn time.Duration
+}
+
+// IncrWithTTL adds n to the counter at key and returns the new value. The ttl is set
+// atomically when the counter has none, i.e. on the first increment, and kept by the others.
+// A ttl of 0 keeps the counter without expiry.
+func (r *Redis) IncrWithTTL(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error) {
+	return incrWithTTLScript.Run(ctx, r.RedisClient, []string{r.getKey(key)}, n, ttl.Milliseconds()).Int64()
+}
+
+/*
+ConsumeQuota adds n to the quota at key unless it would go over limit, in which case
+Quota.Allowed is false and nothing is consumed. The quota resets period after its first use,
+a period of 0 never resets it.
+
+Example:
+
+	quota, err := redis.ConsumeQuota(ctx, "quota:sms:branch:12", 1, 500, 24*time.Hour)
+	if err == nil && !quota.Allowed {
+		return ErrSMSQuotaExceeded
+	}
+*/
+func (r *Redis) ConsumeQuota(ctx context.Context, key string, n, limit int64, period time.Duration) (Quota, error) {
+	res, err := quotaScript.Run(ctx, r.RedisClient, []string{r.getKey(key)}, n, limit, period.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Quota{}, err
+	}
+
+	quota := Quota{Allowed: res[0] == 1, Used: res[1], Remaining: limit - res[1]}
+	if res[2] > 0 {
+		quota.ResetIn = time.Duration(res[2]) * time.Millisecond
+	}
+	if quota.Remaining < 0 {
+		quota.Remaining = 0
+	}
+	return quota, nil
+}
+
+/*
+IncrFixedWindow adds n to the counter of the current window, e.g. the current hour, and
+returns its value. Each window has its own key, expiring with the window.
+
+Example:
+
+	orders, err := redis.IncrFixedWindow(ctx, "orders:branch:12", 1, time.Hour)
+*/
+func (r *Redis) IncrFixedWindow(ctx context.Context, key string, n int64, window time.Duration) (int64, error) {
+	key, ttl := fixedWindow(key, window, r.clock())
+	return r.IncrWithTTL(ctx, key, n, ttl)
+}
+
+// FixedWindowCount returns the counter of the current window, see IncrFixedWindow
+func (r *Redis) FixedWindowCount(ctx context.Context, key string, window time.Duration) (int64, error) {
+	key, _ = fixedWindow(key, window, r.clock())
+	n, err := r.RedisClient.Get(ctx, r.getKey(key)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return n, err
+}
+
+/*
+IncrRollingWindow adds n to the counter at key and returns the sum of the last window,
+e.g. the orders of the last 60 minutes whenever it is called. The counter is kept in 60
+buckets, so the window moves by steps of window/60.
+*/
+func (r *Redis) IncrRollingWindow(ctx context.Context, key string, n int64, window time.Duration) (int64, error) {
+	size := window.Milliseconds() / rollingBuckets
+	if size < 1 {
+		size = 1
+	}
+	return rollingWindowScript.Run(ctx, r.RedisClient, []string{r.getKey(key)}, n, r.clock().UnixMilli(), window.Milliseconds(), size).Int64()
+}
+
+// RollingWindowCount returns the sum of the last window, see IncrRollingWindow
+func (r *Redis) RollingWindowCount(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return r.IncrRollingWindow(ctx, key, 0, window)
+}
+
+// clock returns the current time of the windows
+func (r *Redis) clock() time.Time {
+	if r.now == nil {
+		return time.Now()
+	}
+	return r.now()
+}
+
+// fixedWindow returns the key of the window of key containing t and the ttl until the window ends
+func fixedWindow(key string, window time.Duration, t time.Time) (string, time.Duration) {
+	size := window.Milliseconds()
+	if size < 1 {
+		size = 1
+	}
+	now := t.UnixMilli()
+	start := now - now%size
+	return key + ":" + strconv.FormatInt(start, 10), time.Duration(start+size-now) * time.Millisecond
+}
@@ -0,0 +1,126 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setClock makes the clock of r return start moved by the returned func
+func setClock(r *Redis, start time.Time) func(time.Duration) {
+	now := start
+	r.now = func() time.Time { return now }
+	return func(elapsed time.Duration) { now = start.Add(elapsed) }
+}
+
+func TestRedis_IncrWithTTL(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	n, err := r.IncrWithTTL(ctx, "otp:attempts", 1, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, time.Minute, mr.TTL("test:otp:attempts"))
+
+	// the ttl of the first increment is kept
+	mr.FastForward(30 * time.Second)
+	n, err = r.IncrWithTTL(ctx, "otp:attempts", 2, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, 30*time.Second, mr.TTL("test:otp:attempts"))
+
+	mr.FastForward(30 * time.Second)
+	assert.False(t, mr.Exists("test:otp:attempts"))
+
+	_, err = r.IncrWithTTL(ctx, "forever", 1, 0)
+	require.NoError(t, err)
+	assert.True(t, mr.Exists("test:forever"))
+	assert.Equal(t, time.Duration(0), mr.TTL("test:forever"))
+}
+
+func TestRedis_ConsumeQuota(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	quota, err := r.ConsumeQuota(ctx, "quota:sms", 3, 5, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, Quota{Allowed: true, Used: 3, Remaining: 2, ResetIn: time.Hour}, quota)
+
+	// nothing is consumed over the limit
+	quota, err = r.ConsumeQuota(ctx, "quota:sms", 3, 5, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, Quota{Allowed: false, Used: 3, Remaining: 2, ResetIn: time.Hour}, quota)
+
+	mr.FastForward(10 * time.Minute)
+	quota, err = r.ConsumeQuota(ctx, "quota:sms", 2, 5, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, Quota{Allowed: true, Used: 5, Remaining: 0, ResetIn: 50 * time.Minute}, quota)
+
+	mr.FastForward(50 * time.Minute)
+	quota, err = r.ConsumeQuota(ctx, "quota:sms", 1, 5, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, quota.Allowed)
+	assert.Equal(t, int64(1), quota.Used)
+}
+
+func TestRedis_IncrFixedWindow(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	move := setClock(r, start.Add(45*time.Minute))
+
+	n, err := r.IncrFixedWindow(ctx, "orders", 1, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = r.IncrFixedWindow(ctx, "orders", 2, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	key := "test:orders:" + "1767261600000"
+	assert.True(t, mr.Exists(key))
+	assert.Equal(t, 15*time.Minute, mr.TTL(key))
+
+	// the next window starts from 0
+	move(70 * time.Minute)
+	count, err := r.FixedWindowCount(ctx, "orders", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+	n, err = r.IncrFixedWindow(ctx, "orders", 1, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	count, err = r.FixedWindowCount(ctx, "orders", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestRedis_IncrRollingWindow(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	move := setClock(r, time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC))
+
+	n, err := r.IncrRollingWindow(ctx, "orders", 1, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	move(30 * time.Minute)
+	n, err = r.IncrRollingWindow(ctx, "orders", 2, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	// the first order leaves the window
+	move(61 * time.Minute)
+	count, err := r.RollingWindowCount(ctx, "orders", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	fields, err := mr.HKeys("test:orders")
+	require.NoError(t, err)
+	assert.Len(t, fields, 1)
+
+	move(91 * time.Minute)
+	count, err = r.RollingWindowCount(ctx, "orders", time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
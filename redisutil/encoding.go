@@ -0,0 +1,78 @@
+package redisutil
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+/*
+Values written with a Codec or Compression start with a header byte:
+
+	1cccczzz
+
+where cccc is the Codec ID and zzz the Compression. Plain json never starts with a byte
+>= 0x80, so values without a header are decoded as json, the format written before codecs.
+*/
+const (
+	headerFlag      = 0x80
+	maxCodecID      = 0x0f
+	codecShift      = 3
+	compressionMask = 0x07
+)
+
+// encoder encodes the values of a Redis, the zero encoder writes plain json
+type encoder struct {
+	codec       Codec
+	compression Compression
+	threshold   int
+}
+
+func (e encoder) encode(v interface{}) ([]byte, error) {
+	if e.codec == nil && e.compression == NoCompression {
+		return json.Marshal(v)
+	}
+
+	codec := e.codec
+	if codec == nil {
+		codec = JSONCodec
+	}
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	compression := NoCompression
+	if e.compression != NoCompression && len(data) >= e.threshold {
+		compression = e.compression
+		if data, err = compression.compress(data); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]byte, 0, len(data)+1)
+	out = append(out, headerFlag|codec.ID()<<codecShift|byte(compression))
+	return append(out, data...), nil
+}
+
+// decode reads values written with any codec and compression, or plain json
+func (e encoder) decode(data []byte, v interface{}) error {
+	if len(data) == 0 || data[0]&headerFlag == 0 {
+		return json.Unmarshal(data, v)
+	}
+
+	header := data[0]
+	id := header &^ headerFlag >> codecShift
+	codec, ok := codecByID(id)
+	if e.codec != nil && e.codec.ID() == id {
+		codec, ok = e.codec, true
+	}
+	if !ok {
+		return fmt.Errorf("redisutil: unknown codec id %d, register it with RegisterCodec", id)
+	}
+
+	data, err := Compression(header & compressionMask).decompress(data[1:])
+	if err != nil {
+		return err
+	}
+	return codec.Unmarshal(data, v)
+}
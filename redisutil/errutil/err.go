@@ -6,4 +6,14 @@ import (
 
 var (
 	ErrEmptyRedisKeyValue = errors.New("empty redisutil key or value")
+	ErrCacheMiss          = errors.New("redisutil cache miss")
+	// ErrNotFound is returned by a GetOrLoad loader when the value doesn't exist at the source,
+	// it is cached as a negative result
+	ErrNotFound = errors.New("redisutil value not found")
+	// ErrLockNotObtained is returned when the lock is held by someone else
+	ErrLockNotObtained = errors.New("redisutil lock not obtained")
+	// ErrLockNotHeld is returned when releasing or extending a lock which expired or was taken over
+	ErrLockNotHeld = errors.New("redisutil lock not held")
+	// ErrMaxKeysExceeded is returned when a pattern matches more keys than allowed by WithMaxKeys
+	ErrMaxKeysExceeded = errors.New("redisutil pattern matches more keys than allowed")
 )
@@ -0,0 +1,26 @@
+package redisutil
+
+import (
+	"context"
+	"time"
+)
+
+// Expire sets the ttl of key, false means the key doesn't exist
+func (r *Redis) Expire(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return r.RedisClient.Expire(ctx, r.getKey(key), ttl).Result()
+}
+
+// ExpireAt makes key expire at t, false means the key doesn't exist
+func (r *Redis) ExpireAt(ctx context.Context, key string, t time.Time) (bool, error) {
+	return r.RedisClient.ExpireAt(ctx, r.getKey(key), t).Result()
+}
+
+// TTL returns the remaining ttl of key, -1 when it has no expiry and -2 when it doesn't exist
+func (r *Redis) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return r.RedisClient.PTTL(ctx, r.getKey(key)).Result()
+}
+
+// Persist removes the ttl of key, false means the key doesn't exist or has no ttl
+func (r *Redis) Persist(ctx context.Context, key string) (bool, error) {
+	return r.RedisClient.Persist(ctx, r.getKey(key)).Result()
+}
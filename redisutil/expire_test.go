@@ -0,0 +1,45 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_expire(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	require.NoError(t, mr.Set("test:session", "v"))
+
+	ttl, err := r.TTL(ctx, "session")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), ttl)
+
+	ok, err := r.Expire(ctx, "session", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ttl, err = r.TTL(ctx, "session")
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	ok, err = r.Persist(ctx, "session")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Zero(t, mr.TTL("test:session"))
+
+	mr.SetTime(time.Now())
+	ok, err = r.ExpireAt(ctx, "session", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.InDelta(t, time.Hour, mr.TTL("test:session"), float64(time.Second))
+
+	ok, err = r.Expire(ctx, "missing", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ttl, err = r.TTL(ctx, "missing")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(-2), ttl)
+}
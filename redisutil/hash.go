@@ -0,0 +1,80 @@
+package redisutil
+
+import (
+	"context"
+
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+	"github.com/redis/go-redis/v9"
+)
+
+// HSet sets the fields of the hash at key
+func (r *Redis) HSet(ctx context.Context, key string, values map[string]interface{}) error {
+	if key == "" || len(values) == 0 {
+		return errutil.ErrEmptyRedisKeyValue
+	}
+
+	return r.RedisClient.HSet(ctx, r.getKey(key), values).Err()
+}
+
+// HGet returns the field of the hash at key, redis.Nil when it doesn't exist
+func (r *Redis) HGet(ctx context.Context, key, field string) (string, error) {
+	return r.RedisClient.HGet(ctx, r.getKey(key), field).Result()
+}
+
+// HGetAll returns every field of the hash at key, empty when it doesn't exist
+func (r *Redis) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return r.RedisClient.HGetAll(ctx, r.getKey(key)).Result()
+}
+
+// HDel removes the fields of the hash at key and returns the number removed
+func (r *Redis) HDel(ctx context.Context, key string, fields ...string) (int64, error) {
+	return r.RedisClient.HDel(ctx, r.getKey(key), fields...).Result()
+}
+
+// HExists reports whether the field of the hash at key exists
+func (r *Redis) HExists(ctx context.Context, key, field string) (bool, error) {
+	return r.RedisClient.HExists(ctx, r.getKey(key), field).Result()
+}
+
+// HIncrBy adds incr to the field of the hash at key and returns the new value
+func (r *Redis) HIncrBy(ctx context.Context, key, field string, incr int64) (int64, error) {
+	return r.RedisClient.HIncrBy(ctx, r.getKey(key), field, incr).Result()
+}
+
+/*
+HSetStruct stores the fields of the struct tagged with `redis:"name"` in the hash at key,
+untagged fields are skipped. Unlike SetStruct single fields can be read and updated in place.
+
+Example:
+
+	type Stock struct {
+		ItemID   int    `redis:"item_id"`
+		Quantity int    `redis:"quantity"`
+		Status   string `redis:"status"`
+	}
+
+	err := redis.HSetStruct(ctx, "stock:12", Stock{ItemID: 12, Quantity: 5})
+	quantity, err := redis.HIncrBy(ctx, "stock:12", "quantity", -1)
+*/
+func (r *Redis) HSetStruct(ctx context.Context, key string, value interface{}) error {
+	if key == "" {
+		return errutil.ErrEmptyRedisKeyValue
+	}
+
+	return r.RedisClient.HSet(ctx, r.getKey(key), value).Err()
+}
+
+// HGetStruct scans the hash at key into the struct pointed by dst using its `redis` tags,
+// redis.Nil is returned when the hash doesn't exist
+func (r *Redis) HGetStruct(ctx context.Context, key string, dst interface{}) error {
+	cmd := r.RedisClient.HGetAll(ctx, r.getKey(key))
+	values, err := cmd.Result()
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return redis.Nil
+	}
+
+	return cmd.Scan(dst)
+}
@@ -0,0 +1,66 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stock struct {
+	ItemID   int    `redis:"item_id"`
+	Quantity int    `redis:"quantity"`
+	Status   string `redis:"status"`
+	Internal string
+}
+
+func TestRedis_hash(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	require.NoError(t, r.HSet(ctx, "user:1", map[string]interface{}{"name": "rahim", "visits": 1}))
+	assert.Equal(t, "rahim", mr.HGet("test:user:1", "name"))
+
+	name, err := r.HGet(ctx, "user:1", "name")
+	require.NoError(t, err)
+	assert.Equal(t, "rahim", name)
+	_, err = r.HGet(ctx, "user:1", "missing")
+	assert.ErrorIs(t, err, redis.Nil)
+
+	visits, err := r.HIncrBy(ctx, "user:1", "visits", 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), visits)
+
+	exists, err := r.HExists(ctx, "user:1", "visits")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	removed, err := r.HDel(ctx, "user:1", "visits")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+
+	all, err := r.HGetAll(ctx, "user:1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "rahim"}, all)
+}
+
+func TestRedis_HSetStruct(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	require.NoError(t, r.HSetStruct(ctx, "stock:12", stock{ItemID: 12, Quantity: 5, Status: "available", Internal: "skipped"}))
+	fields, err := mr.HKeys("test:stock:12")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"item_id", "quantity", "status"}, fields)
+
+	_, err = r.HIncrBy(ctx, "stock:12", "quantity", -1)
+	require.NoError(t, err)
+
+	var got stock
+	require.NoError(t, r.HGetStruct(ctx, "stock:12", &got))
+	assert.Equal(t, stock{ItemID: 12, Quantity: 4, Status: "available"}, got)
+
+	assert.ErrorIs(t, r.HGetStruct(ctx, "stock:13", &got), redis.Nil)
+}
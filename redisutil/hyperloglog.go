@@ -0,0 +1,51 @@
+package redisutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// pfAddWithTTLScript adds the elements and sets the ttl when the key has none.
+// KEYS[1] hyperloglog, ARGV ttl in ms or 0 for no expiry, elements
+var pfAddWithTTLScript = redis.NewScript(`
+local changed = redis.call("PFADD", KEYS[1], unpack(ARGV, 2))
+if tonumber(ARGV[1]) > 0 and redis.call("PTTL", KEYS[1]) == -1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return changed
+`)
+
+// PFAdd adds the elements to the HyperLogLog at key, true means the estimated count changed
+func (r *Redis) PFAdd(ctx context.Context, key string, elements ...interface{}) (bool, error) {
+	changed, err := r.RedisClient.PFAdd(ctx, r.getKey(key), elements...).Result()
+	return changed == 1, err
+}
+
+/*
+PFAddWithTTL adds the elements to the HyperLogLog at key like PFAdd, setting the ttl
+atomically when the key has none.
+
+Example:
+
+	day := time.Now().Format("2006-01-02")
+	_, err := redis.PFAddWithTTL(ctx, "customers:branch:12:"+day, 7*24*time.Hour, customerID)
+	customers, err := redis.PFCount(ctx, "customers:branch:12:"+day)
+*/
+func (r *Redis) PFAddWithTTL(ctx context.Context, key string, ttl time.Duration, elements ...interface{}) (bool, error) {
+	args := append([]interface{}{ttl.Milliseconds()}, elements...)
+	changed, err := pfAddWithTTLScript.Run(ctx, r.RedisClient, []string{r.getKey(key)}, args...).Int64()
+	return changed == 1, err
+}
+
+// PFCount returns the estimated number of unique elements of the union of the HyperLogLogs,
+// with a standard error of 0.81%
+func (r *Redis) PFCount(ctx context.Context, keys ...string) (int64, error) {
+	return r.RedisClient.PFCount(ctx, r.getKeys(keys)...).Result()
+}
+
+// PFMerge stores the union of the HyperLogLogs at dest, e.g. the weekly uniques from the daily ones
+func (r *Redis) PFMerge(ctx context.Context, dest string, keys ...string) error {
+	return r.RedisClient.PFMerge(ctx, r.getKey(dest), r.getKeys(keys)...).Err()
+}
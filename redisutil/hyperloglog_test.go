@@ -0,0 +1,48 @@
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_PFAdd(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+
+	changed, err := r.PFAdd(ctx, "customers:monday", "a", "b", "c")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	changed, err = r.PFAdd(ctx, "customers:monday", "a")
+	require.NoError(t, err)
+	assert.False(t, changed)
+	_, err = r.PFAdd(ctx, "customers:tuesday", "c", "d")
+	require.NoError(t, err)
+
+	count, err := r.PFCount(ctx, "customers:monday")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+	require.NoError(t, r.PFMerge(ctx, "customers:week", "customers:monday", "customers:tuesday"))
+	count, err = r.PFCount(ctx, "customers:week")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), count)
+}
+
+func TestRedis_PFAddWithTTL(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	for i := 0; i < 1000; i++ {
+		_, err := r.PFAddWithTTL(ctx, "customers:branch:12", 24*time.Hour, fmt.Sprint("customer-", i%500))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 24*time.Hour, mr.TTL("test:customers:branch:12"))
+
+	count, err := r.PFCount(ctx, "customers:branch:12")
+	require.NoError(t, err)
+	assert.InDelta(t, 500, count, 500*0.03)
+}
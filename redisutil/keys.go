@@ -0,0 +1,245 @@
+package redisutil
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultScanCount = 1000
+	defaultBatchSize = 500
+)
+
+// ScanProgress is reported by WithProgress after every batch of keys
+type ScanProgress struct {
+	// Matched is the number of keys matching the pattern so far. SCAN may return a key
+	// more than once while the keyspace changes, so it is an upper bound.
+	Matched int64
+	// Deleted is the number of keys removed so far by DelPatternCtx
+	Deleted int64
+}
+
+// ScanOption configures ScanKeys, CountPattern and DelPatternCtx
+type ScanOption func(*scanOptions)
+
+type scanOptions struct {
+	count     int64
+	batchSize int
+	dryRun    bool
+	maxKeys   int64
+	progress  func(ScanProgress)
+}
+
+// WithScanCount sets the COUNT hint of every SCAN call, 1000 by default
+func WithScanCount(count int64) ScanOption {
+	return func(o *scanOptions) {
+		o.count = count
+	}
+}
+
+// WithBatchSize sets the number of keys unlinked per pipeline by DelPatternCtx, 500 by default
+func WithBatchSize(size int) ScanOption {
+	return func(o *scanOptions) {
+		o.batchSize = size
+	}
+}
+
+// WithDryRun makes DelPatternCtx report the matching keys through WithProgress without deleting them
+func WithDryRun() ScanOption {
+	return func(o *scanOptions) {
+		o.dryRun = true
+	}
+}
+
+// WithMaxKeys fails with errutil.ErrMaxKeysExceeded when more than max keys match the pattern,
+// guarding against a pattern matching far more than intended. DelPatternCtx counts the keys
+// first and deletes nothing when there are too many.
+func WithMaxKeys(max int64) ScanOption {
+	return func(o *scanOptions) {
+		o.maxKeys = max
+	}
+}
+
+// WithProgress calls fn after every batch of keys, the calls never overlap
+func WithProgress(fn func(ScanProgress)) ScanOption {
+	return func(o *scanOptions) {
+		o.progress = fn
+	}
+}
+
+// ScanKeys returns the keys matching the pattern without the Prefix, on a cluster every master node is scanned
+func (r *Redis) ScanKeys(ctx context.Context, pattern string, opts ...ScanOption) ([]string, error) {
+	var mu sync.Mutex
+	seen := make(map[string]struct{})
+	keys := []string{}
+
+	_, err := r.walkKeys(ctx, pattern, opts, func(ctx context.Context, client redis.Cmdable, batch []string) (int64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, key := range batch {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			keys = append(keys, strings.TrimPrefix(key, r.Prefix))
+		}
+		return 0, nil
+	})
+	return keys, err
+}
+
+// CountPattern returns the number of keys matching the pattern, see ScanProgress.Matched
+func (r *Redis) CountPattern(ctx context.Context, pattern string, opts ...ScanOption) (int64, error) {
+	progress, err := r.walkKeys(ctx, pattern, opts, func(ctx context.Context, client redis.Cmdable, batch []string) (int64, error) {
+		return 0, nil
+	})
+	return progress.Matched, err
+}
+
+/*
+DelPatternCtx deletes the keys matching the pattern with UNLINK, batched in pipelines.
+On a cluster every master node is scanned.
+
+With WithMaxKeys the keyspace is scanned twice, the keys are counted before any is deleted.
+Keys created between both scans can still exceed max during the deletion, which then stops
+with errutil.ErrMaxKeysExceeded.
+
+Example:
+
+	err := redis.DelPatternCtx(ctx, "menu:*",
+		redisutil.WithMaxKeys(100000),
+		redisutil.WithProgress(func(p redisutil.ScanProgress) {
+			logger.Info("deleted ", p.Deleted, " menu keys")
+		}),
+	)
+*/
+func (r *Redis) DelPatternCtx(ctx context.Context, pattern string, opts ...ScanOption) error {
+	var o scanOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.maxKeys > 0 && !o.dryRun {
+		// the count isn't reported to WithProgress
+		countOpts := append(append([]ScanOption{}, opts...), WithProgress(nil))
+		if _, err := r.CountPattern(ctx, pattern, countOpts...); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.walkKeys(ctx, pattern, opts, func(ctx context.Context, client redis.Cmdable, batch []string) (int64, error) {
+		if o.dryRun {
+			return 0, nil
+		}
+
+		cmds := make([]*redis.IntCmd, len(batch))
+		_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range batch {
+				cmds[i] = pipe.Unlink(ctx, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		var deleted int64
+		for _, cmd := range cmds {
+			deleted += cmd.Val()
+		}
+		return deleted, nil
+	})
+	return err
+}
+
+// keyWalker shares the counters of a scan across the master nodes of a cluster
+type keyWalker struct {
+	o        scanOptions
+	mu       sync.Mutex
+	progress ScanProgress
+}
+
+// walkKeys scans the prefixed pattern on every node and passes the keys to fn in batches,
+// fn returns the number of keys it deleted
+func (r *Redis) walkKeys(ctx context.Context, pattern string, opts []ScanOption, fn func(ctx context.Context, client redis.Cmdable, batch []string) (int64, error)) (ScanProgress, error) {
+	w := &keyWalker{o: scanOptions{count: defaultScanCount, batchSize: defaultBatchSize}}
+	for _, opt := range opts {
+		opt(&w.o)
+	}
+	pattern = r.getKey(pattern)
+
+	var err error
+	if cluster, ok := r.RedisClient.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+			return w.walk(ctx, master, pattern, fn)
+		})
+	} else {
+		err = w.walk(ctx, r.RedisClient, pattern, fn)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.progress, err
+}
+
+func (w *keyWalker) walk(ctx context.Context, client redis.Cmdable, pattern string, fn func(ctx context.Context, client redis.Cmdable, batch []string) (int64, error)) error {
+	batch := make([]string, 0, w.o.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		deleted, err := fn(ctx, client, batch)
+		w.report(deleted)
+		batch = batch[:0]
+		return err
+	}
+
+	iter := client.Scan(ctx, 0, pattern, w.o.count).Iterator()
+	for iter.Next(ctx) {
+		if !w.reserve() {
+			if err := flush(); err != nil {
+				return err
+			}
+			return errutil.ErrMaxKeysExceeded
+		}
+
+		batch = append(batch, iter.Val())
+		if len(batch) >= w.o.batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+// reserve counts a matching key, false means WithMaxKeys is reached
+func (w *keyWalker) reserve() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.o.maxKeys > 0 && w.progress.Matched >= w.o.maxKeys {
+		return false
+	}
+	w.progress.Matched++
+	return true
+}
+
+func (w *keyWalker) report(deleted int64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.progress.Deleted += deleted
+	if w.o.progress != nil {
+		w.o.progress(w.progress)
+	}
+}
@@ -0,0 +1,68 @@
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeys(t *testing.T, n int) (*Redis, func() int) {
+	r, mr := newTestRedis(t)
+	for i := 0; i < n; i++ {
+		require.NoError(t, mr.Set(fmt.Sprintf("test:menu:%d", i), "v"))
+	}
+	require.NoError(t, mr.Set("test:order:1", "v"))
+	require.NoError(t, mr.Set("menu:unprefixed", "v"))
+
+	remaining := func() int {
+		return len(mr.Keys()) - 2
+	}
+	return r, remaining
+}
+
+func TestRedis_ScanKeys(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestKeys(t, 3)
+
+	keys, err := r.ScanKeys(ctx, "menu:*", WithScanCount(1))
+	require.NoError(t, err)
+	sort.Strings(keys)
+	assert.Equal(t, []string{"menu:0", "menu:1", "menu:2"}, keys)
+
+	keys, err = r.ScanKeys(ctx, "menu:*", WithMaxKeys(2))
+	assert.ErrorIs(t, err, errutil.ErrMaxKeysExceeded)
+	assert.Len(t, keys, 2)
+
+	count, err := r.CountPattern(ctx, "menu:*")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+}
+
+func TestRedis_DelPatternCtx(t *testing.T) {
+	ctx := context.Background()
+	r, remaining := newTestKeys(t, 30)
+
+	var reports []ScanProgress
+	progress := WithProgress(func(p ScanProgress) { reports = append(reports, p) })
+
+	require.NoError(t, r.DelPatternCtx(ctx, "menu:*", WithDryRun(), WithBatchSize(10), progress))
+	assert.Equal(t, 30, remaining())
+	assert.Equal(t, ScanProgress{Matched: 30}, reports[len(reports)-1])
+
+	// nothing is deleted when more keys than max match
+	reports = nil
+	err := r.DelPatternCtx(ctx, "menu:*", WithMaxKeys(12), WithBatchSize(5), progress)
+	assert.ErrorIs(t, err, errutil.ErrMaxKeysExceeded)
+	assert.Equal(t, 30, remaining())
+	assert.Empty(t, reports)
+
+	reports = nil
+	require.NoError(t, r.DelPatternCtx(ctx, "menu:*", WithMaxKeys(30), WithBatchSize(10), progress))
+	assert.Equal(t, 0, remaining())
+	assert.Equal(t, []ScanProgress{{Matched: 10, Deleted: 10}, {Matched: 20, Deleted: 20}, {Matched: 30, Deleted: 30}}, reports)
+}
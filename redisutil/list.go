@@ -0,0 +1,48 @@
+package redisutil
+
+import (
+	"context"
+)
+
+// LPush prepends the values to the list at key and returns its new length
+func (r *Redis) LPush(ctx context.Context, key string, values ...interface{}) (int64, error) {
+	return r.RedisClient.LPush(ctx, r.getKey(key), values...).Result()
+}
+
+// RPush appends the values to the list at key and returns its new length
+func (r *Redis) RPush(ctx context.Context, key string, values ...interface{}) (int64, error) {
+	return r.RedisClient.RPush(ctx, r.getKey(key), values...).Result()
+}
+
+// LPop removes and returns the first element of the list at key, redis.Nil when it is empty
+func (r *Redis) LPop(ctx context.Context, key string) (string, error) {
+	return r.RedisClient.LPop(ctx, r.getKey(key)).Result()
+}
+
+// RPop removes and returns the last element of the list at key, redis.Nil when it is empty
+func (r *Redis) RPop(ctx context.Context, key string) (string, error) {
+	return r.RedisClient.RPop(ctx, r.getKey(key)).Result()
+}
+
+// LRange returns the elements of the list at key from start to stop included,
+// negative indexes count from the end, e.g. 0, -1 is the whole list
+func (r *Redis) LRange(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	return r.RedisClient.LRange(ctx, r.getKey(key), start, stop).Result()
+}
+
+// LLen returns the length of the list at key
+func (r *Redis) LLen(ctx context.Context, key string) (int64, error) {
+	return r.RedisClient.LLen(ctx, r.getKey(key)).Result()
+}
+
+// LTrim keeps only the elements of the list at key from start to stop included,
+// e.g. LPush then LTrim 0, 99 keeps the latest 100 entries
+func (r *Redis) LTrim(ctx context.Context, key string, start, stop int64) error {
+	return r.RedisClient.LTrim(ctx, r.getKey(key), start, stop).Err()
+}
+
+// LRem removes count occurrences of value from the list at key, all of them when count is 0,
+// and returns the number removed
+func (r *Redis) LRem(ctx context.Context, key string, count int64, value interface{}) (int64, error) {
+	return r.RedisClient.LRem(ctx, r.getKey(key), count, value).Result()
+}
@@ -0,0 +1,41 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_list(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	_, err := r.RPush(ctx, "events", "b", "c")
+	require.NoError(t, err)
+	n, err := r.LPush(ctx, "events", "a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.True(t, mr.Exists("test:events"))
+
+	values, err := r.LRange(ctx, "events", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, values)
+
+	require.NoError(t, r.LTrim(ctx, "events", 0, 1))
+	length, err := r.LLen(ctx, "events")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), length)
+
+	removed, err := r.LRem(ctx, "events", 0, "b")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+
+	first, err := r.LPop(ctx, "events")
+	require.NoError(t, err)
+	assert.Equal(t, "a", first)
+	_, err = r.RPop(ctx, "events")
+	assert.ErrorIs(t, err, redis.Nil)
+}
@@ -0,0 +1,221 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/mostakim64/golang-utils/logger"
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+	"github.com/redis/go-redis/v9"
+)
+
+// Loader loads the value of a key from the source of truth, e.g. the database.
+// It returns errutil.ErrNotFound when the value doesn't exist.
+type Loader[T any] func(ctx context.Context) (T, error)
+
+// LoadOption configures GetOrLoad
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	beta        float64
+	staleTTL    time.Duration
+	negativeTTL time.Duration
+	tags        []string
+}
+
+// WithEarlyRefresh enables probabilistic early refresh (XFetch). Shortly before the value
+// expires a single request reloads it in the background, the closer to the expiry and the
+// slower the loader the more likely. beta 1 is the usual choice, a bigger beta refreshes earlier.
+func WithEarlyRefresh(beta float64) LoadOption {
+	return func(o *loadOptions) {
+		o.beta = beta
+	}
+}
+
+// WithStaleWhileRevalidate keeps an expired value for staleTTL longer, during which
+// the stale value is returned while it is reloaded in the background
+func WithStaleWhileRevalidate(staleTTL time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.staleTTL = staleTTL
+	}
+}
+
+// WithNegativeTTL caches errutil.ErrNotFound returned by the loader for ttl,
+// so missing values don't hit the source on every request
+func WithNegativeTTL(ttl time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.negativeTTL = ttl
+	}
+}
+
+// WithTags adds the loaded key to the tags, see Redis.InvalidateTags
+func WithTags(tags ...string) LoadOption {
+	return func(o *loadOptions) {
+		o.tags = tags
+	}
+}
+
+// envelope is the value stored by GetOrLoad along with what is needed to refresh it
+type envelope[T any] struct {
+	Value T `json:"value"`
+	// Delta is how long the loader took in milliseconds
+	Delta int64 `json:"delta"`
+	// Expiry is when the value becomes stale in unix milliseconds
+	Expiry  int64 `json:"expiry"`
+	Missing bool  `json:"missing,omitempty"`
+}
+
+func (e envelope[T]) result() (T, error) {
+	if e.Missing {
+		return e.Value, errutil.ErrNotFound
+	}
+	return e.Value, nil
+}
+
+/*
+GetOrLoad returns the value at key, calling loader and caching its result for ttl on a miss.
+Concurrent misses of the same key in the process are collapsed into a single loader call.
+
+The loader runs detached from the cancellation of ctx so a cancelled request doesn't fail
+the others waiting for the same key. A redis failure falls back to calling the loader.
+
+Values written by GetOrLoad carry refresh metadata, read them through GetOrLoad only.
+
+Example:
+
+	menu, err := menuCache.GetOrLoad(ctx, "menu:12", 10*time.Minute, func(ctx context.Context) (Menu, error) {
+		return repo.FindMenu(ctx, 12)
+	}, redisutil.WithEarlyRefresh(1), redisutil.WithNegativeTTL(time.Minute))
+*/
+func (c *Cache[T]) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader Loader[T], opts ...LoadOption) (T, error) {
+	var o loadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if key == "" {
+		var zero T
+		return zero, errutil.ErrEmptyRedisKeyValue
+	}
+
+	env, ok, err := c.getEnvelope(ctx, key)
+	if err != nil {
+		logger.Warn("redisutil: failed to read ", key, ", loading from source: ", err)
+	}
+
+	if ok {
+		now := time.Now()
+		switch {
+		case now.UnixMilli() < env.Expiry:
+			if !env.Missing && o.beta > 0 && xfetch(now, env.Delta, env.Expiry, o.beta) {
+				c.refresh(ctx, key, ttl, loader, o)
+			}
+			c.redis.observeCache(true)
+			return env.result()
+		case !env.Missing && o.staleTTL > 0:
+			c.refresh(ctx, key, ttl, loader, o)
+			c.redis.observeCache(true)
+			return env.result()
+		}
+	}
+	c.redis.observeCache(false)
+
+	ch := c.group.DoChan(c.redis.getKey(key), func() (interface{}, error) {
+		return c.load(detach(ctx), key, ttl, loader, o)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			var zero T
+			return zero, res.Err
+		}
+		return res.Val.(envelope[T]).result()
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// refresh reloads the key in the background unless a load of the key is already running
+func (c *Cache[T]) refresh(ctx context.Context, key string, ttl time.Duration, loader Loader[T], o loadOptions) {
+	ctx = detach(ctx)
+	go func() {
+		_, err, _ := c.group.Do(c.redis.getKey(key), func() (interface{}, error) {
+			return c.load(ctx, key, ttl, loader, o)
+		})
+		if err != nil {
+			logger.Warn("redisutil: failed to refresh ", key, ": ", err)
+		}
+	}()
+}
+
+// load calls the loader and stores its result, a failure to store is logged only
+func (c *Cache[T]) load(ctx context.Context, key string, ttl time.Duration, loader Loader[T], o loadOptions) (envelope[T], error) {
+	start := time.Now()
+	value, err := loader(ctx)
+	delta := time.Since(start)
+
+	env := envelope[T]{Value: value, Delta: delta.Milliseconds()}
+	storeTTL := ttl + o.staleTTL
+	switch {
+	case errors.Is(err, errutil.ErrNotFound) && o.negativeTTL > 0:
+		var zero T
+		env = envelope[T]{Value: zero, Delta: delta.Milliseconds(), Missing: true}
+		ttl, storeTTL = o.negativeTTL, o.negativeTTL
+	case err != nil:
+		return env, err
+	}
+	env.Expiry = time.Now().Add(ttl).UnixMilli()
+
+	b, mErr := c.redis.encoder.encode(env)
+	if mErr != nil {
+		return env, mErr
+	}
+	if sErr := c.redis.setTagged(ctx, key, b, storeTTL, o.tags); sErr != nil {
+		logger.Warn("redisutil: failed to cache ", key, ": ", sErr)
+	}
+
+	return env, nil
+}
+
+func (c *Cache[T]) getEnvelope(ctx context.Context, key string) (envelope[T], bool, error) {
+	var env envelope[T]
+	b, err := c.redis.RedisClient.Get(ctx, c.redis.getKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return env, false, nil
+	}
+	if err != nil {
+		return env, false, err
+	}
+
+	if err := c.redis.encoder.decode(b, &env); err != nil {
+		return env, false, err
+	}
+	return env, true, nil
+}
+
+// xfetch reports whether the value should be refreshed early, see
+// "Optimal Probabilistic Cache Stampede Prevention" by Vattani et al.
+func xfetch(now time.Time, deltaMs, expiryMs int64, beta float64) bool {
+	gap := float64(deltaMs) * beta * -math.Log(1-rand.Float64())
+	return float64(now.UnixMilli())+gap >= float64(expiryMs)
+}
+
+// detachedContext keeps the values of the parent context, e.g. the trace span,
+// but is never cancelled
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
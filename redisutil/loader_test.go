@@ -0,0 +1,150 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func countingLoader(calls *int32, value menu, delay time.Duration) Loader[menu] {
+	return func(ctx context.Context) (menu, error) {
+		atomic.AddInt32(calls, 1)
+		time.Sleep(delay)
+		return value, nil
+	}
+}
+
+func TestCache_GetOrLoad(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	cache := NewCache[menu](r)
+	var calls int32
+
+	value, err := cache.GetOrLoad(ctx, "menu:1", time.Minute, countingLoader(&calls, menu{ID: 1}, 0))
+	require.NoError(t, err)
+	assert.Equal(t, menu{ID: 1}, value)
+	assert.True(t, mr.Exists("test:menu:1"))
+
+	value, err = cache.GetOrLoad(ctx, "menu:1", time.Minute, countingLoader(&calls, menu{ID: 2}, 0))
+	require.NoError(t, err)
+	assert.Equal(t, menu{ID: 1}, value)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestCache_GetOrLoad_collapses_concurrent_misses(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	cache := NewCache[menu](r)
+	var calls int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := cache.GetOrLoad(ctx, "menu:1", time.Minute, countingLoader(&calls, menu{ID: 1}, 50*time.Millisecond))
+			assert.NoError(t, err)
+			assert.Equal(t, 1, value.ID)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestCache_GetOrLoad_loader_error(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	cache := NewCache[menu](r)
+	errDB := errors.New("db down")
+
+	_, err := cache.GetOrLoad(ctx, "menu:1", time.Minute, func(ctx context.Context) (menu, error) {
+		return menu{}, errDB
+	})
+	assert.ErrorIs(t, err, errDB)
+	assert.False(t, mr.Exists("test:menu:1"))
+}
+
+func TestCache_GetOrLoad_negative_cache(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	cache := NewCache[menu](r)
+	var calls int32
+	loader := func(ctx context.Context) (menu, error) {
+		atomic.AddInt32(&calls, 1)
+		return menu{}, errutil.ErrNotFound
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := cache.GetOrLoad(ctx, "menu:404", time.Minute, loader, WithNegativeTTL(5*time.Second))
+		assert.ErrorIs(t, err, errutil.ErrNotFound)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, 5*time.Second, mr.TTL("test:menu:404"))
+
+	// without a negative ttl nothing is cached
+	for i := 0; i < 2; i++ {
+		_, err := cache.GetOrLoad(ctx, "menu:405", time.Minute, loader)
+		assert.ErrorIs(t, err, errutil.ErrNotFound)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestCache_GetOrLoad_stale_while_revalidate(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	cache := NewCache[menu](r)
+	var calls int32
+
+	_, err := cache.GetOrLoad(ctx, "menu:1", 20*time.Millisecond, countingLoader(&calls, menu{ID: 1}, 0), WithStaleWhileRevalidate(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute+20*time.Millisecond, mr.TTL("test:menu:1"))
+
+	time.Sleep(30 * time.Millisecond)
+	value, err := cache.GetOrLoad(ctx, "menu:1", 20*time.Millisecond, countingLoader(&calls, menu{ID: 2}, 0), WithStaleWhileRevalidate(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, menu{ID: 1}, value)
+
+	assert.Eventually(t, func() bool {
+		var stored menu
+		stored, err = cache.GetOrLoad(ctx, "menu:1", time.Minute, countingLoader(&calls, menu{ID: 3}, 0))
+		return err == nil && stored.ID == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCache_GetOrLoad_early_refresh(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	cache := NewCache[menu](r)
+	var calls int32
+
+	_, err := cache.GetOrLoad(ctx, "menu:1", time.Minute, countingLoader(&calls, menu{ID: 1}, 5*time.Millisecond))
+	require.NoError(t, err)
+
+	// a huge beta makes the early refresh certain while the value is still fresh
+	value, err := cache.GetOrLoad(ctx, "menu:1", time.Minute, countingLoader(&calls, menu{ID: 2}, 0), WithEarlyRefresh(1e9))
+	require.NoError(t, err)
+	assert.Equal(t, menu{ID: 1}, value)
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&calls) == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCache_GetOrLoad_cancelled_context(t *testing.T) {
+	r, _ := newTestRedis(t)
+	cache := NewCache[menu](r)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err := cache.GetOrLoad(ctx, "menu:1", time.Minute, countingLoader(new(int32), menu{ID: 1}, 100*time.Millisecond))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,316 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/mostakim64/golang-utils/logger"
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	lockKeyPrefix          = "lock:"
+	defaultLockBackoff     = 50 * time.Millisecond
+	defaultLockMaxBackoff  = time.Second
+	lockClockDriftFactor   = 0.01
+	lockClockDriftConstant = 2 * time.Millisecond
+)
+
+// releaseScript deletes the lock only if it still holds our token
+var releaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+// extendScript resets the ttl of the lock only if it still holds our token
+var extendScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// LockOption configures Lock and TryLock
+type LockOption func(*lockOptions)
+
+type lockOptions struct {
+	backoff    time.Duration
+	maxBackoff time.Duration
+	noRenewal  bool
+}
+
+// WithLockBackoff sets the wait between attempts of Lock, doubled on every attempt up to maxBackoff
+func WithLockBackoff(backoff, maxBackoff time.Duration) LockOption {
+	return func(o *lockOptions) {
+		o.backoff = backoff
+		o.maxBackoff = maxBackoff
+	}
+}
+
+// WithoutLockRenewal disables the automatic renewal, the lock expires after ttl unless extended
+func WithoutLockRenewal() LockOption {
+	return func(o *lockOptions) {
+		o.noRenewal = true
+	}
+}
+
+// Redlock acquires locks on a majority of independent redis instances, so the lock
+// survives the failure of a minority of them
+type Redlock struct {
+	instances []*Redis
+}
+
+// NewRedlock returns a Redlock over independent redis instances, they must not be replicas of each other
+//
+// Example:
+//
+//	locker := redisutil.NewRedlock(redis1, redis2, redis3)
+//	lock, err := locker.Lock(ctx, "orders:12", 10*time.Second)
+func NewRedlock(instances ...*Redis) *Redlock {
+	return &Redlock{instances: instances}
+}
+
+// Lock is a held distributed lock. It is renewed in the background until Unlock
+// unless WithoutLockRenewal is used.
+type Lock struct {
+	instances []*Redis
+	key       string
+	token     string
+	ttl       time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	lost   chan struct{}
+}
+
+/*
+Lock acquires the lock name for ttl, retrying with backoff until ctx is done, in which case
+the error is errutil.ErrLockNotObtained. Transient errors, e.g. a timeout or redis loading
+its data, are retried as well. The lock is released with Unlock.
+
+Example:
+
+	lock, err := redis.Lock(ctx, "cron:daily-report", 30*time.Second)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock(context.Background())
+*/
+func (r *Redis) Lock(ctx context.Context, name string, ttl time.Duration, opts ...LockOption) (*Lock, error) {
+	return NewRedlock(r).Lock(ctx, name, ttl, opts...)
+}
+
+// TryLock acquires the lock name for ttl with a single attempt, errutil.ErrLockNotObtained
+// is returned when it is held by someone else or ctx is done
+func (r *Redis) TryLock(ctx context.Context, name string, ttl time.Duration, opts ...LockOption) (*Lock, error) {
+	return NewRedlock(r).TryLock(ctx, name, ttl, opts...)
+}
+
+// Lock acquires the lock name on a majority of the instances, see Redis.Lock
+func (rl *Redlock) Lock(ctx context.Context, name string, ttl time.Duration, opts ...LockOption) (*Lock, error) {
+	o := lockOptions{backoff: defaultLockBackoff, maxBackoff: defaultLockMaxBackoff}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	backoff := o.backoff
+	for {
+		lock, err := rl.acquire(ctx, name, ttl, o)
+		if err == nil || (!errors.Is(err, errutil.ErrLockNotObtained) && !isTransientError(err)) {
+			return lock, err
+		}
+		if !errors.Is(err, errutil.ErrLockNotObtained) {
+			logger.Warn("redisutil: failed to acquire lock ", name, ", retrying: ", err)
+		}
+
+		timer := time.NewTimer(backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w: %s", errutil.ErrLockNotObtained, ctx.Err())
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > o.maxBackoff {
+			backoff = o.maxBackoff
+		}
+	}
+}
+
+// TryLock acquires the lock name on a majority of the instances with a single attempt, see Redis.TryLock
+func (rl *Redlock) TryLock(ctx context.Context, name string, ttl time.Duration, opts ...LockOption) (*Lock, error) {
+	var o lockOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return rl.acquire(ctx, name, ttl, o)
+}
+
+// acquire sets the lock on every instance and keeps it if a majority succeeded within the ttl
+func (rl *Redlock) acquire(ctx context.Context, name string, ttl time.Duration, o lockOptions) (*Lock, error) {
+	l := &Lock{
+		instances: rl.instances,
+		key:       lockKeyPrefix + name,
+		token:     randomID(),
+		ttl:       ttl,
+	}
+
+	start := time.Now()
+	acquired := 0
+	var lastErr error
+	for _, r := range l.instances {
+		ok, err := r.RedisClient.SetNX(ctx, r.getKey(l.key), l.token, ttl).Result()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if ok {
+			acquired++
+		}
+	}
+
+	drift := time.Duration(float64(ttl)*lockClockDriftFactor) + lockClockDriftConstant
+	validity := ttl - time.Since(start) - drift
+	if acquired >= l.quorum() && validity > 0 {
+		l.done = make(chan struct{})
+		l.lost = make(chan struct{})
+		if o.noRenewal {
+			close(l.done)
+		} else {
+			renewCtx, cancel := context.WithCancel(detach(ctx))
+			l.cancel = cancel
+			go l.renew(renewCtx)
+		}
+		return l, nil
+	}
+
+	// release the minority of the instances we got, the lock may be retried
+	_, _ = l.run(detach(ctx), releaseScript)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("%w: %s", errutil.ErrLockNotObtained, ctx.Err())
+	}
+	if acquired == 0 && lastErr != nil && len(l.instances) == 1 {
+		return nil, lastErr
+	}
+	return nil, errutil.ErrLockNotObtained
+}
+
+// isTransientError reports whether err may go away on retry, like the retries of go-redis:
+// network errors and redis loading, failing over or out of connections
+func isTransientError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, redis.ErrPoolTimeout) {
+		return true
+	}
+	for _, prefix := range []string{"LOADING", "READONLY", "MASTERDOWN", "CLUSTERDOWN", "TRYAGAIN", "max number of clients reached"} {
+		if redis.HasErrorPrefix(err, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Token returns the unique value identifying this holder of the lock
+func (l *Lock) Token() string {
+	return l.token
+}
+
+// Lost is closed when the automatic renewal finds the lock expired or taken over,
+// the work protected by the lock should be stopped
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Extend resets the ttl of the lock, errutil.ErrLockNotHeld is returned when it was lost
+func (l *Lock) Extend(ctx context.Context, ttl time.Duration) error {
+	held, err := l.run(ctx, extendScript, ttl.Milliseconds())
+	if held >= l.quorum() {
+		return nil
+	}
+	if err != nil && len(l.instances) == 1 {
+		return err
+	}
+	return errutil.ErrLockNotHeld
+}
+
+// Unlock stops the renewal and releases the lock, errutil.ErrLockNotHeld is returned
+// when it expired or was taken over before
+func (l *Lock) Unlock(ctx context.Context) error {
+	if l.cancel != nil {
+		l.cancel()
+	}
+	<-l.done
+
+	released, err := l.run(ctx, releaseScript)
+	if released >= l.quorum() {
+		return nil
+	}
+	if err != nil && len(l.instances) == 1 {
+		return err
+	}
+	return errutil.ErrLockNotHeld
+}
+
+// renew extends the lock every third of its ttl until cancelled or lost
+func (l *Lock) renew(ctx context.Context) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	lastRenewal := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		extendCtx, cancel := context.WithTimeout(ctx, l.ttl/3)
+		err := l.Extend(extendCtx, l.ttl)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case err == nil:
+			lastRenewal = time.Now()
+		case errors.Is(err, errutil.ErrLockNotHeld), time.Since(lastRenewal) >= l.ttl:
+			logger.Warn("redisutil: lost lock ", l.key, ": ", err)
+			close(l.lost)
+			return
+		default:
+			logger.Warn("redisutil: failed to renew lock ", l.key, ", retrying: ", err)
+		}
+	}
+}
+
+// run runs the script on every instance and returns the number which returned 1
+func (l *Lock) run(ctx context.Context, script *redis.Script, args ...interface{}) (int, error) {
+	succeeded := 0
+	var lastErr error
+	for _, r := range l.instances {
+		res, err := script.Run(ctx, r.RedisClient, []string{r.getKey(l.key)}, append([]interface{}{l.token}, args...)...).Int64()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if res == 1 {
+			succeeded++
+		}
+	}
+	return succeeded, lastErr
+}
+
+func (l *Lock) quorum() int {
+	return len(l.instances)/2 + 1
+}
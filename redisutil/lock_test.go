@@ -0,0 +1,158 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	lock, err := r.TryLock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, lock.Token(), lockToken(t, mr, "test:lock:job"))
+
+	_, err = r.TryLock(ctx, "job", time.Minute)
+	assert.ErrorIs(t, err, errutil.ErrLockNotObtained)
+
+	require.NoError(t, lock.Unlock(ctx))
+	assert.False(t, mr.Exists("test:lock:job"))
+
+	other, err := r.TryLock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, other.Unlock(ctx))
+}
+
+func TestLock_blocking(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+
+	lock, err := r.Lock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	_, err = r.Lock(timeoutCtx, "job", time.Minute, WithLockBackoff(10*time.Millisecond, 20*time.Millisecond))
+	assert.ErrorIs(t, err, errutil.ErrLockNotObtained)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = lock.Unlock(ctx)
+	}()
+	other, err := r.Lock(ctx, "job", time.Minute, WithLockBackoff(10*time.Millisecond, 20*time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, other.Unlock(ctx))
+}
+
+func TestLock_context_done(t *testing.T) {
+	r, _ := newTestRedis(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := r.TryLock(ctx, "job", time.Minute)
+	assert.ErrorIs(t, err, errutil.ErrLockNotObtained)
+	_, err = r.Lock(ctx, "job", time.Minute)
+	assert.ErrorIs(t, err, errutil.ErrLockNotObtained)
+}
+
+func TestLock_transient_errors(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	// retried until redis is done loading
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		mr.SetError("")
+	}()
+	lock, err := r.Lock(ctx, "job", time.Minute, WithLockBackoff(10*time.Millisecond, 20*time.Millisecond))
+	require.NoError(t, err)
+	require.NoError(t, lock.Unlock(ctx))
+
+	// other errors are returned at once
+	mr.SetError("NOPERM this user has no permissions")
+	defer mr.SetError("")
+	_, err = r.Lock(ctx, "job", time.Minute)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errutil.ErrLockNotObtained)
+}
+
+func TestLock_taken_over(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	lock, err := r.TryLock(ctx, "job", time.Minute, WithoutLockRenewal())
+	require.NoError(t, err)
+
+	mr.FastForward(time.Minute)
+	other, err := r.TryLock(ctx, "job", time.Minute, WithoutLockRenewal())
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, lock.Extend(ctx, time.Minute), errutil.ErrLockNotHeld)
+	assert.ErrorIs(t, lock.Unlock(ctx), errutil.ErrLockNotHeld)
+	assert.Equal(t, other.Token(), lockToken(t, mr, "test:lock:job"))
+}
+
+func TestLock_renewal(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	lock, err := r.TryLock(ctx, "job", 300*time.Millisecond)
+	require.NoError(t, err)
+
+	mr.FastForward(250 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return mr.TTL("test:lock:job") > 250*time.Millisecond
+	}, time.Second, 10*time.Millisecond)
+
+	mr.Del("test:lock:job")
+	select {
+	case <-lock.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lock loss was not detected")
+	}
+	assert.ErrorIs(t, lock.Unlock(ctx), errutil.ErrLockNotHeld)
+}
+
+func TestRedlock(t *testing.T) {
+	ctx := context.Background()
+	servers := make([]*miniredis.Miniredis, 3)
+	instances := make([]*Redis, 3)
+	for i := range servers {
+		servers[i] = miniredis.RunT(t)
+		r, err := New(Options{Host: servers[i].Host(), Port: servers[i].Port(), MaxRetries: -1})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = r.RedisClient.Close() })
+		instances[i] = r
+	}
+	locker := NewRedlock(instances...)
+
+	servers[0].SetError("LOADING redis is loading the dataset in memory")
+	lock, err := locker.TryLock(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, lock.Token(), lockToken(t, servers[1], "lock:job"))
+	assert.Equal(t, lock.Token(), lockToken(t, servers[2], "lock:job"))
+
+	_, err = locker.TryLock(ctx, "job", time.Minute)
+	assert.ErrorIs(t, err, errutil.ErrLockNotObtained)
+	require.NoError(t, lock.Unlock(ctx))
+
+	// a minority holding the lock is released again
+	require.NoError(t, servers[1].Set("lock:job", "someone else"))
+	servers[2].SetError("LOADING redis is loading the dataset in memory")
+	_, err = locker.TryLock(ctx, "job", time.Minute)
+	assert.ErrorIs(t, err, errutil.ErrLockNotObtained)
+}
+
+func lockToken(t *testing.T, mr *miniredis.Miniredis, key string) string {
+	token, err := mr.Get(key)
+	require.NoError(t, err)
+	return token
+}
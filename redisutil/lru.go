@@ -0,0 +1,102 @@
+package redisutil
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type lruEntry[T any] struct {
+	key     string
+	value   T
+	expires time.Time
+}
+
+// lru is an in-memory least recently used cache with a per entry expiry
+type lru[T any] struct {
+	mu         sync.Mutex
+	maxEntries int
+	items      *list.List
+	index      map[string]*list.Element
+}
+
+func newLRU[T any](maxEntries int) *lru[T] {
+	return &lru[T]{
+		maxEntries: maxEntries,
+		items:      list.New(),
+		index:      make(map[string]*list.Element),
+	}
+}
+
+func (c *lru[T]) get(key string) (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero T
+	elem, ok := c.index[key]
+	if !ok {
+		return zero, false
+	}
+
+	entry := elem.Value.(*lruEntry[T])
+	if time.Now().After(entry.expires) {
+		c.removeElement(elem)
+		return zero, false
+	}
+
+	c.items.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (c *lru[T]) set(key string, value T, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(ttl)
+	if elem, ok := c.index[key]; ok {
+		entry := elem.Value.(*lruEntry[T])
+		entry.value, entry.expires = value, expires
+		c.items.MoveToFront(elem)
+		return
+	}
+
+	c.index[key] = c.items.PushFront(&lruEntry[T]{key: key, value: value, expires: expires})
+	for c.maxEntries > 0 && c.items.Len() > c.maxEntries {
+		c.removeElement(c.items.Back())
+	}
+}
+
+func (c *lru[T]) remove(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := c.index[key]; ok {
+			c.removeElement(elem)
+		}
+	}
+}
+
+func (c *lru[T]) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items.Init()
+	c.index = make(map[string]*list.Element)
+}
+
+func (c *lru[T]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.items.Len()
+}
+
+func (c *lru[T]) removeElement(elem *list.Element) {
+	c.items.Remove(elem)
+	delete(c.index, elem.Value.(*lruEntry[T]).key)
+}
@@ -0,0 +1,285 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mostakim64/golang-utils/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// defaultMetricsNamespace matches the subsystem of the echo metrics of monitor
+	defaultMetricsNamespace = "klikit"
+	defaultMetricsClient    = "default"
+	pipelineCommand         = "pipeline"
+)
+
+// InstrumentOptions configures Redis.Instrument
+type InstrumentOptions struct {
+	// Registerer registers the metrics, prometheus.DefaultRegisterer by default which is
+	// served by the metrics endpoint of monitor.NewEchoPrometheusClient
+	Registerer prometheus.Registerer
+	// Namespace of the metric names, "klikit" by default
+	Namespace string
+	// Client is the value of the client label, to tell several Redis apart, "default" by default
+	Client string
+	// Buckets of the command duration histogram in seconds, 0.5ms to 1s by default
+	Buckets []float64
+	// SlowThreshold logs the commands slower than it with logger.Warn, 0 disables the log
+	SlowThreshold time.Duration
+}
+
+// metrics are shared by every instrumented Redis of a registerer, told apart by the client label
+type metrics struct {
+	client   string
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+	cache    *prometheus.CounterVec
+}
+
+// metricsHook is the go-redis hook recording the commands
+type metricsHook struct {
+	metrics       *metrics
+	slowThreshold time.Duration
+}
+
+/*
+Instrument records the commands of r as Prometheus metrics and logs the slow ones. Call it
+once, right after connecting.
+
+  - <namespace>_redis_command_duration_seconds{client, command} histogram, a pipeline is
+    recorded once as the "pipeline" command. Blocking commands like BLPOP or XREADGROUP
+    with BLOCK wait by design, so they are neither recorded nor logged as slow.
+  - <namespace>_redis_command_errors_total{client, command}, redis.Nil and the NOSCRIPT
+    replies of scripts which are then loaded aren't errors
+  - <namespace>_redis_cache_requests_total{client, result} with result hit or miss, counted
+    by Cache, TieredCache and GetOrLoad
+  - <namespace>_redis_pool_* connection pool stats
+
+Example:
+
+	monitor.NewEchoPrometheusClient(e, nil)
+	err := redis.Instrument(redisutil.InstrumentOptions{SlowThreshold: 50 * time.Millisecond})
+*/
+func (r *Redis) Instrument(opts InstrumentOptions) error {
+	if opts.Registerer == nil {
+		opts.Registerer = prometheus.DefaultRegisterer
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = defaultMetricsNamespace
+	}
+	if opts.Client == "" {
+		opts.Client = defaultMetricsClient
+	}
+	if len(opts.Buckets) == 0 {
+		opts.Buckets = prometheus.ExponentialBuckets(0.0005, 2, 12)
+	}
+
+	m := &metrics{client: opts.Client}
+	var err error
+	if m.duration, err = registerVec(opts.Registerer, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: opts.Namespace,
+		Subsystem: "redis",
+		Name:      "command_duration_seconds",
+		Help:      "Duration of the redis commands.",
+		Buckets:   opts.Buckets,
+	}, []string{"client", "command"})); err != nil {
+		return err
+	}
+	if m.errors, err = registerVec(opts.Registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: "redis",
+		Name:      "command_errors_total",
+		Help:      "Number of failed redis commands.",
+	}, []string{"client", "command"})); err != nil {
+		return err
+	}
+	if m.cache, err = registerVec(opts.Registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: opts.Namespace,
+		Subsystem: "redis",
+		Name:      "cache_requests_total",
+		Help:      "Number of cache lookups by result, hit or miss.",
+	}, []string{"client", "result"})); err != nil {
+		return err
+	}
+	if err := opts.Registerer.Register(newPoolCollector(r.RedisClient, opts.Namespace, opts.Client)); err != nil {
+		return err
+	}
+
+	r.metrics = m
+	r.RedisClient.AddHook(metricsHook{metrics: m, slowThreshold: opts.SlowThreshold})
+	return nil
+}
+
+// registerVec registers the vector or returns the one registered by another Redis
+func registerVec[V prometheus.Collector](registerer prometheus.Registerer, vec V) (V, error) {
+	err := registerer.Register(vec)
+	var registered prometheus.AlreadyRegisteredError
+	if errors.As(err, &registered) {
+		if existing, ok := registered.ExistingCollector.(V); ok {
+			return existing, nil
+		}
+	}
+	return vec, err
+}
+
+// observeCache counts a cache lookup, a no-op unless Instrument was called
+func (r *Redis) observeCache(hit bool) {
+	if r.metrics == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	r.metrics.cache.WithLabelValues(r.metrics.client, result).Inc()
+}
+
+func (h metricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h metricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		elapsed := time.Since(start)
+		// the error is set on the command only after the hooks return
+		if isCommandError(err) {
+			h.metrics.errors.WithLabelValues(h.metrics.client, cmd.Name()).Inc()
+		}
+		if !isBlockingCommand(cmd) {
+			h.observe(ctx, cmd.Name(), elapsed, []redis.Cmder{cmd})
+		}
+		return err
+	}
+}
+
+func (h metricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		elapsed := time.Since(start)
+		blocking := false
+		for _, cmd := range cmds {
+			if isCommandError(cmd.Err()) {
+				h.metrics.errors.WithLabelValues(h.metrics.client, cmd.Name()).Inc()
+			}
+			blocking = blocking || isBlockingCommand(cmd)
+		}
+		if !blocking {
+			h.observe(ctx, pipelineCommand, elapsed, cmds)
+		}
+		return err
+	}
+}
+
+func (h metricsHook) observe(ctx context.Context, name string, elapsed time.Duration, cmds []redis.Cmder) {
+	h.metrics.duration.WithLabelValues(h.metrics.client, name).Observe(elapsed.Seconds())
+
+	if h.slowThreshold > 0 && elapsed >= h.slowThreshold {
+		logger.WarnCtx(ctx, "redisutil: slow ", describeCommands(name, cmds), " took ", elapsed)
+	}
+}
+
+// blockingCommands wait for data up to their timeout, their duration says nothing about redis
+var blockingCommands = map[string]bool{
+	"blpop":      true,
+	"brpop":      true,
+	"brpoplpush": true,
+	"blmove":     true,
+	"blmpop":     true,
+	"bzpopmin":   true,
+	"bzpopmax":   true,
+	"bzmpop":     true,
+	"wait":       true,
+}
+
+// isBlockingCommand reports whether cmd waits for data, XREAD and XREADGROUP only with BLOCK
+func isBlockingCommand(cmd redis.Cmder) bool {
+	name := cmd.Name()
+	if blockingCommands[name] {
+		return true
+	}
+	if name != "xread" && name != "xreadgroup" {
+		return false
+	}
+	for _, arg := range cmd.Args() {
+		if s, ok := arg.(string); ok && strings.EqualFold(s, "block") {
+			return true
+		}
+	}
+	return false
+}
+
+// isCommandError reports whether err is counted as a failed command. A missing key is not a
+// failure, nor is NOSCRIPT which go-redis answers by loading the script.
+func isCommandError(err error) bool {
+	return err != nil && !errors.Is(err, redis.Nil) && !redis.HasErrorPrefix(err, "NOSCRIPT")
+}
+
+// describeCommands names the commands and their first key, leaving the values out of the log
+func describeCommands(name string, cmds []redis.Cmder) string {
+	if name == pipelineCommand {
+		return fmt.Sprintf("pipeline of %d commands", len(cmds))
+	}
+
+	args := cmds[0].Args()
+	if len(args) < 2 {
+		return "command " + strings.ToUpper(name)
+	}
+	return fmt.Sprintf("command %s %v", strings.ToUpper(name), args[1])
+}
+
+// poolCollector exposes the connection pool stats of a client on scrape
+type poolCollector struct {
+	client    redis.UniversalClient
+	hits      *prometheus.Desc
+	misses    *prometheus.Desc
+	timeouts  *prometheus.Desc
+	total     *prometheus.Desc
+	idle      *prometheus.Desc
+	stale     *prometheus.Desc
+	waitCount *prometheus.Desc
+}
+
+func newPoolCollector(client redis.UniversalClient, namespace, label string) *poolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "redis", name), help, nil, prometheus.Labels{"client": label})
+	}
+	return &poolCollector{
+		client:    client,
+		hits:      desc("pool_hits_total", "Number of times a free connection was found in the pool."),
+		misses:    desc("pool_misses_total", "Number of times a free connection was not found in the pool."),
+		timeouts:  desc("pool_timeouts_total", "Number of times waiting for a connection timed out."),
+		waitCount: desc("pool_waits_total", "Number of times a connection was waited for."),
+		total:     desc("pool_connections", "Number of connections in the pool."),
+		idle:      desc("pool_idle_connections", "Number of idle connections in the pool."),
+		stale:     desc("pool_stale_connections_total", "Number of stale connections removed from the pool."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{c.hits, c.misses, c.timeouts, c.waitCount, c.total, c.idle, c.stale} {
+		ch <- desc
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(c.stale, prometheus.CounterValue, float64(stats.StaleConns))
+}
@@ -0,0 +1,178 @@
+package redisutil
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mostakim64/golang-utils/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_Instrument(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	registry := prometheus.NewRegistry()
+	require.NoError(t, r.Instrument(InstrumentOptions{Registerer: registry}))
+
+	c := NewCache[menu](r)
+	require.NoError(t, c.Set(ctx, "menu:1", menu{ID: 1}, time.Minute))
+	_, _, err := c.Get(ctx, "menu:1")
+	require.NoError(t, err)
+	_, _, err = c.Get(ctx, "menu:2")
+	assert.Error(t, err)
+	_, err = c.MGet(ctx, "menu:1", "menu:2", "menu:3")
+	require.NoError(t, err)
+	require.NoError(t, r.Pipeline(ctx, func(p *Pipe) error {
+		p.Incr("count")
+		p.Incr("count")
+		return nil
+	}))
+	// a wrong type error
+	_, err = r.HGetAll(ctx, "menu:1")
+	assert.Error(t, err)
+
+	assert.Equal(t, float64(2), testutil.ToFloat64(r.metrics.cache.WithLabelValues("default", "hit")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(r.metrics.cache.WithLabelValues("default", "miss")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(r.metrics.errors.WithLabelValues("default", "hgetall")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(r.metrics.errors.WithLabelValues("default", "get")))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	names := map[string]bool{}
+	for _, family := range families {
+		names[family.GetName()] = true
+	}
+	for _, name := range []string{
+		"klikit_redis_command_duration_seconds",
+		"klikit_redis_command_errors_total",
+		"klikit_redis_cache_requests_total",
+		"klikit_redis_pool_connections",
+		"klikit_redis_pool_hits_total",
+	} {
+		assert.True(t, names[name], name)
+	}
+
+	count, err := testutil.GatherAndCount(registry, "klikit_redis_command_duration_seconds")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, count, 4) // set, get, mget pipeline, hgetall
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP klikit_redis_cache_requests_total Number of cache lookups by result, hit or miss.
+# TYPE klikit_redis_cache_requests_total counter
+klikit_redis_cache_requests_total{client="default",result="hit"} 2
+klikit_redis_cache_requests_total{client="default",result="miss"} 3
+`), "klikit_redis_cache_requests_total"))
+}
+
+func TestRedis_Instrument_several_clients(t *testing.T) {
+	ctx := context.Background()
+	registry := prometheus.NewRegistry()
+	orders, _ := newTestRedis(t)
+	sessions, _ := newTestRedis(t)
+	require.NoError(t, orders.Instrument(InstrumentOptions{Registerer: registry, Client: "orders"}))
+	require.NoError(t, sessions.Instrument(InstrumentOptions{Registerer: registry, Client: "sessions"}))
+	// the same client twice would report its pool stats twice
+	assert.Error(t, sessions.Instrument(InstrumentOptions{Registerer: registry, Client: "sessions"}))
+
+	_, _, err := NewCache[menu](orders).Get(ctx, "menu:1")
+	assert.Error(t, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(orders.metrics.cache.WithLabelValues("orders", "miss")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(sessions.metrics.cache.WithLabelValues("sessions", "miss")))
+
+	_, err = registry.Gather()
+	assert.NoError(t, err)
+}
+
+func TestDescribeCommands(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+
+	cmd := r.RedisClient.Set(ctx, "test:menu:1", "secret value", 0)
+	assert.Equal(t, "command SET test:menu:1", describeCommands("set", []redis.Cmder{cmd}))
+	ping := r.RedisClient.Ping(ctx)
+	assert.Equal(t, "command PING", describeCommands("ping", []redis.Cmder{ping}))
+	assert.Equal(t, "pipeline of 2 commands", describeCommands(pipelineCommand, []redis.Cmder{cmd, ping}))
+}
+
+func TestRedis_Instrument_pipeline_errors(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	require.NoError(t, r.Instrument(InstrumentOptions{Registerer: prometheus.NewRegistry()}))
+	require.NoError(t, mr.Set("test:menu:1", "x"))
+
+	err := r.Pipeline(ctx, func(p *Pipe) error {
+		p.HGetAll("menu:1")
+		p.Get("menu:2")
+		return nil
+	})
+	assert.Error(t, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(r.metrics.errors.WithLabelValues("default", "hgetall")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(r.metrics.errors.WithLabelValues("default", "get")))
+	assert.Equal(t, 1, testutil.CollectAndCount(r.metrics.duration))
+}
+
+func TestRedis_Instrument_slow_commands(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	require.NoError(t, r.Instrument(InstrumentOptions{Registerer: prometheus.NewRegistry(), SlowThreshold: time.Nanosecond}))
+
+	var out bytes.Buffer
+	l := logger.NewLoggerClient()
+	l.Logrus().SetOutput(&out)
+	previous := logger.Default()
+	logger.SetDefault(l)
+	t.Cleanup(func() { logger.SetDefault(previous) })
+
+	require.NoError(t, r.SetStringCtx(ctx, "menu:1", "secret value", 0))
+	assert.Contains(t, out.String(), "redisutil: slow command SET test:menu:1 took")
+	assert.NotContains(t, out.String(), "secret value")
+}
+
+func TestRedis_Instrument_blocking_commands(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	require.NoError(t, r.Instrument(InstrumentOptions{Registerer: prometheus.NewRegistry(), SlowThreshold: time.Nanosecond}))
+
+	var out bytes.Buffer
+	l := logger.NewLoggerClient()
+	l.Logrus().SetOutput(&out)
+	previous := logger.Default()
+	logger.SetDefault(l)
+	t.Cleanup(func() { logger.SetDefault(previous) })
+
+	// waiting for data is neither recorded nor slow
+	err := r.RedisClient.BLPop(ctx, time.Second, "test:jobs").Err()
+	assert.ErrorIs(t, err, redis.Nil)
+	err = r.RedisClient.XRead(ctx, &redis.XReadArgs{Streams: []string{"test:stream", "$"}, Block: 20 * time.Millisecond}).Err()
+	assert.ErrorIs(t, err, redis.Nil)
+	assert.Equal(t, 0, testutil.CollectAndCount(r.metrics.duration))
+	assert.Empty(t, out.String())
+
+	// XREAD without BLOCK returns at once
+	require.NoError(t, r.RedisClient.XAdd(ctx, &redis.XAddArgs{Stream: "test:stream", Values: []string{"a", "1"}}).Err())
+	require.NoError(t, r.RedisClient.XRead(ctx, &redis.XReadArgs{Streams: []string{"test:stream", "0"}, Block: -1}).Err())
+	assert.Equal(t, 2, testutil.CollectAndCount(r.metrics.duration)) // xadd, xread
+	assert.Contains(t, out.String(), "redisutil: slow command XREAD")
+}
+
+func TestRedis_Instrument_scripts(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	require.NoError(t, r.Instrument(InstrumentOptions{Registerer: prometheus.NewRegistry()}))
+
+	// the first run gets NOSCRIPT and loads the script
+	script := redis.NewScript(`return 1`)
+	require.NoError(t, script.Run(ctx, r.RedisClient, nil).Err())
+	_, err := r.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		redis.NewScript(`return 2`).EvalSha(ctx, pipe, nil)
+		return nil
+	})
+	assert.True(t, redis.HasErrorPrefix(err, "NOSCRIPT"))
+	assert.Equal(t, float64(0), testutil.ToFloat64(r.metrics.errors.WithLabelValues("default", "evalsha")))
+}
@@ -0,0 +1,211 @@
+package redisutil
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mostakim64/golang-utils/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRetryBackoff    = 500 * time.Millisecond
+	defaultMaxRetryBackoff = 10 * time.Second
+)
+
+// Options configures the redis connection created by New
+type Options struct {
+	Host string
+	Port string
+	// Username enables redis 6 ACL authentication, leave empty to authenticate with Password only
+	Username string
+	Password string
+	DB       int
+	Prefix   string
+
+	// SentinelUsername and SentinelPassword authenticate to the sentinels of NewFailover,
+	// which often have credentials of their own. Username and Password authenticate to the master.
+	SentinelUsername string
+	SentinelPassword string
+
+	// TLSConfig enables TLS when not nil
+	TLSConfig *tls.Config
+
+	PoolSize     int
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	PoolTimeout  time.Duration
+	// MaxRetries is the number of retries of a failed command, -1 disables retries
+	MaxRetries int
+
+	// ConnectRetries is the number of extra ping attempts at startup before New gives up
+	ConnectRetries int
+	// RetryBackoff is the wait before the first retry, doubled on every retry up to MaxRetryBackoff
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+
+	// Codec encodes the stored values, nil keeps plain json without a header for older readers.
+	// Its ID must be 1 to 15, the constructors return an error otherwise.
+	Codec Codec
+	// Compression compresses encoded values of at least CompressionThreshold bytes, 1KB by default
+	Compression          Compression
+	CompressionThreshold int
+}
+
+/*
+New connects to the redis instance described by opts and returns the Redis util object.
+The connection is verified with a ping, retried opts.ConnectRetries times with backoff,
+and an error is returned if redis is still unreachable.
+*/
+func New(opts Options) (*Redis, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	return connect(redis.NewClient(opts.redisOptions()), opts.Host+":"+opts.Port, opts)
+}
+
+/*
+NewFailover connects to the redis master named masterName through the sentinels at
+sentinelAddrs and follows failovers. Host and Port of opts are not used, the sentinels are
+authenticated with SentinelUsername and SentinelPassword.
+*/
+func NewFailover(masterName string, sentinelAddrs []string, opts Options) (*Redis, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	redisClient := redis.NewFailoverClient(&redis.FailoverOptions{
+		MasterName:       masterName,
+		SentinelAddrs:    sentinelAddrs,
+		SentinelUsername: opts.SentinelUsername,
+		SentinelPassword: opts.SentinelPassword,
+		Username:         opts.Username,
+		Password:         opts.Password,
+		DB:               opts.DB,
+		DialTimeout:      opts.DialTimeout,
+		ReadTimeout:      opts.ReadTimeout,
+		WriteTimeout:     opts.WriteTimeout,
+		PoolSize:         opts.PoolSize,
+		MinIdleConns:     opts.MinIdleConns,
+		PoolTimeout:      opts.PoolTimeout,
+		MaxRetries:       opts.MaxRetries,
+		TLSConfig:        opts.TLSConfig,
+	})
+
+	return connect(redisClient, "master "+masterName+" via sentinels "+strings.Join(sentinelAddrs, ","), opts)
+}
+
+/*
+NewCluster connects to the redis cluster through the seed nodes at addrs.
+Host, Port and DB of opts are not used, a cluster only has db 0.
+*/
+func NewCluster(addrs []string, opts Options) (*Redis, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	redisClient := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs:        addrs,
+		Username:     opts.Username,
+		Password:     opts.Password,
+		DialTimeout:  opts.DialTimeout,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		PoolSize:     opts.PoolSize,
+		MinIdleConns: opts.MinIdleConns,
+		PoolTimeout:  opts.PoolTimeout,
+		MaxRetries:   opts.MaxRetries,
+		TLSConfig:    opts.TLSConfig,
+	})
+
+	return connect(redisClient, "cluster "+strings.Join(addrs, ","), opts)
+}
+
+// HealthCheck pings redis, it can be used by readiness probes
+func (r *Redis) HealthCheck(ctx context.Context) error {
+	return r.RedisClient.Ping(ctx).Err()
+}
+
+func connect(redisClient redis.UniversalClient, addr string, opts Options) (*Redis, error) {
+	logger.Info("connecting to redis at ", addr, "...")
+	if err := pingWithRetry(redisClient, opts); err != nil {
+		_ = redisClient.Close()
+		return nil, fmt.Errorf("failed to connect redis at %s: %w", addr, err)
+	}
+	logger.Info("redis connection successful...")
+
+	return &Redis{
+		RedisClient: redisClient,
+		Prefix:      opts.Prefix,
+		encoder:     opts.encoder(),
+		now:         time.Now,
+	}, nil
+}
+
+func (opts Options) redisOptions() *redis.Options {
+	return &redis.Options{
+		Addr:         opts.Host + ":" + opts.Port,
+		Username:     opts.Username,
+		Password:     opts.Password,
+		DB:           opts.DB,
+		TLSConfig:    opts.TLSConfig,
+		PoolSize:     opts.PoolSize,
+		MinIdleConns: opts.MinIdleConns,
+		DialTimeout:  opts.DialTimeout,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		PoolTimeout:  opts.PoolTimeout,
+		MaxRetries:   opts.MaxRetries,
+	}
+}
+
+// validate checks the options which would otherwise fail later, when values are read back
+func (opts Options) validate() error {
+	if opts.Codec != nil {
+		return checkCodecID(opts.Codec.ID())
+	}
+	return nil
+}
+
+func (opts Options) encoder() encoder {
+	threshold := opts.CompressionThreshold
+	if threshold <= 0 {
+		threshold = defaultCompressionThreshold
+	}
+
+	return encoder{
+		codec:       opts.Codec,
+		compression: opts.Compression,
+		threshold:   threshold,
+	}
+}
+
+func pingWithRetry(redisClient redis.UniversalClient, opts Options) error {
+	backoff := opts.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	maxBackoff := opts.MaxRetryBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxRetryBackoff
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = redisClient.Ping(context.Background()).Err(); err == nil {
+			return nil
+		}
+		if attempt >= opts.ConnectRetries {
+			return err
+		}
+
+		logger.Warn("redis is not reachable, retrying in ", backoff, ": ", err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptions_redisOptions(t *testing.T) {
+	opts := Options{
+		Host:         "redis.internal",
+		Port:         "6380",
+		Username:     "service",
+		Password:     "secret",
+		DB:           2,
+		PoolSize:     20,
+		MinIdleConns: 5,
+		DialTimeout:  time.Second,
+		ReadTimeout:  2 * time.Second,
+		WriteTimeout: 3 * time.Second,
+		PoolTimeout:  4 * time.Second,
+		MaxRetries:   -1,
+	}
+
+	redisOpts := opts.redisOptions()
+	assert.Equal(t, "redis.internal:6380", redisOpts.Addr)
+	assert.Equal(t, "service", redisOpts.Username)
+	assert.Equal(t, "secret", redisOpts.Password)
+	assert.Equal(t, 2, redisOpts.DB)
+	assert.Equal(t, 20, redisOpts.PoolSize)
+	assert.Equal(t, 5, redisOpts.MinIdleConns)
+	assert.Equal(t, time.Second, redisOpts.DialTimeout)
+	assert.Equal(t, 2*time.Second, redisOpts.ReadTimeout)
+	assert.Equal(t, 3*time.Second, redisOpts.WriteTimeout)
+	assert.Equal(t, 4*time.Second, redisOpts.PoolTimeout)
+	assert.Equal(t, -1, redisOpts.MaxRetries)
+}
+
+func TestNew_options(t *testing.T) {
+	mr := miniredis.RunT(t)
+	r, err := New(Options{
+		Host:                 mr.Host(),
+		Port:                 mr.Port(),
+		DB:                   3,
+		Prefix:               "svc:",
+		Codec:                MsgpackCodec,
+		Compression:          Gzip,
+		CompressionThreshold: 64,
+	})
+	require.NoError(t, err)
+	defer r.RedisClient.Close()
+
+	assert.Equal(t, "svc:", r.Prefix)
+	assert.Equal(t, MsgpackCodec, r.encoder.codec)
+	assert.Equal(t, Gzip, r.encoder.compression)
+	assert.Equal(t, 64, r.encoder.threshold)
+
+	require.NoError(t, r.SetStringCtx(context.Background(), "key", "value", 0))
+	mr.Select(3)
+	assert.True(t, mr.Exists("svc:key"))
+
+	// the compression threshold defaults to 1KB
+	r, err = New(Options{Host: mr.Host(), Port: mr.Port()})
+	require.NoError(t, err)
+	defer r.RedisClient.Close()
+	assert.Equal(t, defaultCompressionThreshold, r.encoder.threshold)
+}
+
+func TestNew_connect_retries(t *testing.T) {
+	mr := miniredis.RunT(t)
+	host, port := mr.Host(), mr.Port()
+	mr.Close()
+
+	// redis comes up while New is retrying
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = mr.Restart()
+	}()
+
+	r, err := New(Options{
+		Host:            host,
+		Port:            port,
+		MaxRetries:      -1,
+		ConnectRetries:  20,
+		RetryBackoff:    10 * time.Millisecond,
+		MaxRetryBackoff: 40 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer r.RedisClient.Close()
+	assert.NoError(t, r.HealthCheck(context.Background()))
+}
+
+// failingPings fails every ping and records when it was sent
+type failingPings struct {
+	sent []time.Time
+}
+
+func (h *failingPings) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *failingPings) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		h.sent = append(h.sent, time.Now())
+		return errors.New("LOADING Redis is loading the dataset in memory")
+	}
+}
+
+func (h *failingPings) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestNew_connect_backoff(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	defer client.Close()
+	pings := &failingPings{}
+	client.AddHook(pings)
+
+	err := pingWithRetry(client, Options{
+		ConnectRetries:  4,
+		RetryBackoff:    20 * time.Millisecond,
+		MaxRetryBackoff: 50 * time.Millisecond,
+	})
+	assert.Error(t, err)
+
+	// the waits double from RetryBackoff and are capped at MaxRetryBackoff
+	require.Len(t, pings.sent, 5)
+	for i, wait := range []time.Duration{20, 40, 50, 50} {
+		gap := pings.sent[i+1].Sub(pings.sent[i])
+		assert.GreaterOrEqual(t, gap, wait*time.Millisecond, "wait %d", i)
+		assert.Less(t, gap, (wait+200)*time.Millisecond, "wait %d", i)
+	}
+
+	// without ConnectRetries it gives up after the first ping
+	pings.sent = nil
+	assert.Error(t, pingWithRetry(client, Options{RetryBackoff: time.Second}))
+	assert.Len(t, pings.sent, 1)
+}
+
+func TestRedis_HealthCheck(t *testing.T) {
+	r, mr := newTestRedis(t)
+	ctx := context.Background()
+	require.NoError(t, r.HealthCheck(ctx))
+
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	assert.Error(t, r.HealthCheck(ctx))
+	mr.SetError("")
+	assert.NoError(t, r.HealthCheck(ctx))
+
+	// the probe fails once redis is gone
+	mr.Close()
+	timeout, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, r.HealthCheck(timeout))
+}
+
+// newTestSentinel returns a sentinel serving addr as the address of the master mymaster
+func newTestSentinel(t *testing.T, addr string) *miniredis.Miniredis {
+	sentinel := miniredis.RunT(t)
+	host, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	err = sentinel.Server().Register("SENTINEL", func(c *server.Peer, cmd string, args []string) {
+		switch {
+		case len(args) == 2 && strings.EqualFold(args[0], "get-master-addr-by-name") && args[1] == "mymaster":
+			c.WriteStrings([]string{host, port})
+		case len(args) == 2 && strings.EqualFold(args[0], "sentinels"):
+			c.WriteLen(0)
+		default:
+			c.WriteNull()
+		}
+	})
+	require.NoError(t, err)
+	return sentinel
+}
+
+func TestNewFailover(t *testing.T) {
+	master := miniredis.RunT(t)
+	master.RequireAuth("master-secret")
+	sentinel := newTestSentinel(t, master.Addr())
+	sentinel.RequireUserAuth("sentinel", "sentinel-secret")
+	opts := Options{
+		Password:         "master-secret",
+		SentinelUsername: "sentinel",
+		SentinelPassword: "sentinel-secret",
+		Prefix:           "svc:",
+		MaxRetries:       -1,
+	}
+
+	r, err := NewFailover("mymaster", []string{sentinel.Addr()}, opts)
+	require.NoError(t, err)
+	defer r.RedisClient.Close()
+	require.NoError(t, r.SetStringCtx(context.Background(), "key", "value", 0))
+	assert.True(t, master.Exists("svc:key"))
+	assert.False(t, sentinel.Exists("svc:key"))
+
+	wrongSentinel := opts
+	wrongSentinel.SentinelPassword = "wrong"
+	_, err = NewFailover("mymaster", []string{sentinel.Addr()}, wrongSentinel)
+	assert.Error(t, err)
+
+	_, err = NewFailover("unknown", []string{sentinel.Addr()}, opts)
+	assert.Error(t, err)
+}
+
+func TestNewCluster(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.RequireAuth("secret")
+	r, err := NewCluster([]string{mr.Addr()}, Options{Password: "secret", Prefix: "svc:"})
+	require.NoError(t, err)
+	defer r.RedisClient.Close()
+	ctx := context.Background()
+
+	_, ok := r.RedisClient.(*redis.ClusterClient)
+	require.True(t, ok)
+	require.NoError(t, r.SetStringCtx(ctx, "menu:1", "a", 0))
+	assert.True(t, mr.Exists("svc:menu:1"))
+
+	// the keys are scanned and deleted on every master
+	for i := 2; i <= 5; i++ {
+		require.NoError(t, r.SetStringCtx(ctx, fmt.Sprint("menu:", i), "a", 0))
+	}
+	require.NoError(t, r.SetStringCtx(ctx, "order:1", "a", 0))
+	keys, err := r.ScanKeys(ctx, "menu:*")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"menu:1", "menu:2", "menu:3", "menu:4", "menu:5"}, keys)
+
+	var progress ScanProgress
+	require.NoError(t, r.DelPatternCtx(ctx, "menu:*", WithBatchSize(2), WithProgress(func(p ScanProgress) { progress = p })))
+	assert.Equal(t, ScanProgress{Matched: 5, Deleted: 5}, progress)
+	assert.Equal(t, []string{"svc:order:1"}, mr.Keys())
+
+	_, err = NewCluster([]string{mr.Addr()}, Options{Password: "wrong", MaxRetries: -1})
+	assert.Error(t, err)
+}
+
+// idCodec is the json codec under another id
+type idCodec struct {
+	Codec
+	id byte
+}
+
+func (c idCodec) ID() byte { return c.id }
+
+func TestNew_codec_id(t *testing.T) {
+	mr := miniredis.RunT(t)
+	for _, id := range []byte{0, 16, 255} {
+		opts := Options{Host: mr.Host(), Port: mr.Port(), Codec: idCodec{Codec: JSONCodec, id: id}}
+		_, err := New(opts)
+		assert.ErrorContains(t, err, "out of range", "id %d", id)
+		_, err = NewFailover("mymaster", []string{mr.Addr()}, opts)
+		assert.ErrorContains(t, err, "out of range", "id %d", id)
+		_, err = NewCluster([]string{mr.Addr()}, opts)
+		assert.ErrorContains(t, err, "out of range", "id %d", id)
+	}
+
+	r, err := New(Options{Host: mr.Host(), Port: mr.Port(), Codec: idCodec{Codec: JSONCodec, id: 15}})
+	require.NoError(t, err)
+	defer r.RedisClient.Close()
+	assert.NoError(t, r.SetStructCtx(context.Background(), "menu:1", menu{ID: 1}, time.Minute))
+}
@@ -0,0 +1,284 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Pipe queues prefixed commands of a Pipeline or Tx. The results of the returned commands
+// are available once the pipeline has run.
+type Pipe struct {
+	r       *Redis
+	ctx     context.Context
+	pipe    redis.Pipeliner
+	decodes []*DecodeCmd
+	// err is the first value which failed to encode, the pipeline isn't sent
+	err error
+}
+
+// DecodeCmd is the result of Pipe.GetStruct, decoded once the pipeline has run
+type DecodeCmd struct {
+	cmd *redis.StringCmd
+	dst interface{}
+	err error
+}
+
+// Err returns the error of the command or of decoding its value, redis.Nil when the key doesn't exist
+func (c *DecodeCmd) Err() error {
+	return c.err
+}
+
+/*
+Pipeline sends the commands queued by fn in a single round trip. The error of the first failed
+command is returned, a missing key is not a failure and is reported by the command itself.
+
+Example:
+
+	err := redis.Pipeline(ctx, func(p *redisutil.Pipe) error {
+		for _, menu := range menus {
+			p.Set("menu:"+menu.ID, menu, time.Hour)
+		}
+		return nil
+	})
+*/
+func (r *Redis) Pipeline(ctx context.Context, fn func(p *Pipe) error) error {
+	return r.runPipe(ctx, r.RedisClient.Pipeline(), fn)
+}
+
+// Tx runs commands in a transaction, see Redis.Tx
+type Tx struct {
+	r  *Redis
+	tx *redis.Tx
+}
+
+/*
+Tx watches keys and calls fn. When a watched key is changed by someone else before the commands
+queued with Tx.Exec run, none of them run and redis.TxFailedErr is returned, the whole Tx can be retried.
+
+Example:
+
+	err := redis.Tx(ctx, func(tx *redisutil.Tx) error {
+		stock, err := tx.GetInt(ctx, "stock:12")
+		if err != nil {
+			return err
+		}
+		if stock == 0 {
+			return ErrOutOfStock
+		}
+		return tx.Exec(ctx, func(p *redisutil.Pipe) error {
+			p.IncrBy("stock:12", -1)
+			return nil
+		})
+	}, "stock:12")
+*/
+func (r *Redis) Tx(ctx context.Context, fn func(tx *Tx) error, keys ...string) error {
+	return r.RedisClient.Watch(ctx, func(tx *redis.Tx) error {
+		return fn(&Tx{r: r, tx: tx})
+	}, r.getKeys(keys)...)
+}
+
+// Get returns the string value of key on the watching connection
+func (t *Tx) Get(ctx context.Context, key string) (string, error) {
+	return t.tx.Get(ctx, t.r.getKey(key)).Result()
+}
+
+// GetInt returns the integer value of key on the watching connection
+func (t *Tx) GetInt(ctx context.Context, key string) (int, error) {
+	return t.tx.Get(ctx, t.r.getKey(key)).Int()
+}
+
+// GetStruct decodes the value of key into dst on the watching connection
+func (t *Tx) GetStruct(ctx context.Context, key string, dst interface{}) error {
+	b, err := t.tx.Get(ctx, t.r.getKey(key)).Bytes()
+	if err != nil {
+		return err
+	}
+	return t.r.encoder.decode(b, dst)
+}
+
+// HGetAll returns every field of the hash at key on the watching connection
+func (t *Tx) HGetAll(ctx context.Context, key string) (map[string]string, error) {
+	return t.tx.HGetAll(ctx, t.r.getKey(key)).Result()
+}
+
+// Exec runs the commands queued by fn atomically with MULTI/EXEC
+func (t *Tx) Exec(ctx context.Context, fn func(p *Pipe) error) error {
+	return t.r.runPipe(ctx, t.tx.TxPipeline(), fn)
+}
+
+func (r *Redis) runPipe(ctx context.Context, pipe redis.Pipeliner, fn func(p *Pipe) error) error {
+	p := &Pipe{r: r, ctx: ctx, pipe: pipe}
+	if err := fn(p); err != nil {
+		pipe.Discard()
+		return err
+	}
+	if p.err != nil {
+		pipe.Discard()
+		return p.err
+	}
+
+	// the replies are decoded even when a command failed, so each DecodeCmd has its own result
+	cmds, err := pipe.Exec(ctx)
+	for _, d := range p.decodes {
+		d.decode(r)
+	}
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	for _, cmd := range cmds {
+		if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *DecodeCmd) decode(r *Redis) {
+	b, err := c.cmd.Bytes()
+	if err != nil {
+		c.err = err
+		return
+	}
+	c.err = r.encoder.decode(b, c.dst)
+}
+
+// Key returns the prefixed key, for commands sent through Pipeliner
+func (p *Pipe) Key(key string) string {
+	return p.r.getKey(key)
+}
+
+// Pipeliner returns the underlying pipeline for commands without a helper, the keys must be prefixed with Key
+func (p *Pipe) Pipeliner() redis.Pipeliner {
+	return p.pipe
+}
+
+// Set queues storing the value encoded like Redis.SetCtx, a ttl of 0 keeps the key without expiry
+func (p *Pipe) Set(key string, value interface{}, ttl time.Duration) *redis.StatusCmd {
+	b, err := p.encode(value)
+	if err != nil {
+		cmd := redis.NewStatusCmd(p.ctx)
+		cmd.SetErr(err)
+		return cmd
+	}
+	return p.pipe.Set(p.ctx, p.r.getKey(key), b, ttl)
+}
+
+// SetXX queues storing the value like Set only if key exists, the result is false when it doesn't
+func (p *Pipe) SetXX(key string, value interface{}, ttl time.Duration) *redis.BoolCmd {
+	b, err := p.encode(value)
+	if err != nil {
+		cmd := redis.NewBoolCmd(p.ctx)
+		cmd.SetErr(err)
+		return cmd
+	}
+	return p.pipe.SetXX(p.ctx, p.r.getKey(key), b, ttl)
+}
+
+// encode encodes a value of the pipeline, the first error is kept so the pipeline isn't sent
+func (p *Pipe) encode(value interface{}) ([]byte, error) {
+	b, err := p.r.encoder.encode(value)
+	if err != nil && p.err == nil {
+		p.err = err
+	}
+	return b, err
+}
+
+// SetString queues storing the value as is
+func (p *Pipe) SetString(key, value string, ttl time.Duration) *redis.StatusCmd {
+	return p.pipe.Set(p.ctx, p.r.getKey(key), value, ttl)
+}
+
+// Get queues reading the string value of key
+func (p *Pipe) Get(key string) *redis.StringCmd {
+	return p.pipe.Get(p.ctx, p.r.getKey(key))
+}
+
+// GetStruct queues reading the value of key, decoded into dst once the pipeline has run
+func (p *Pipe) GetStruct(key string, dst interface{}) *DecodeCmd {
+	d := &DecodeCmd{cmd: p.pipe.Get(p.ctx, p.r.getKey(key)), dst: dst}
+	p.decodes = append(p.decodes, d)
+	return d
+}
+
+// Del queues removing the keys
+func (p *Pipe) Del(keys ...string) *redis.IntCmd {
+	return p.pipe.Del(p.ctx, p.r.getKeys(keys)...)
+}
+
+// Exists queues counting the keys which exist
+func (p *Pipe) Exists(keys ...string) *redis.IntCmd {
+	return p.pipe.Exists(p.ctx, p.r.getKeys(keys)...)
+}
+
+// Incr queues incrementing the integer value of key
+func (p *Pipe) Incr(key string) *redis.IntCmd {
+	return p.pipe.Incr(p.ctx, p.r.getKey(key))
+}
+
+// IncrBy queues adding value to the integer value of key
+func (p *Pipe) IncrBy(key string, value int64) *redis.IntCmd {
+	return p.pipe.IncrBy(p.ctx, p.r.getKey(key), value)
+}
+
+// Expire queues setting the ttl of key
+func (p *Pipe) Expire(key string, ttl time.Duration) *redis.BoolCmd {
+	return p.pipe.Expire(p.ctx, p.r.getKey(key), ttl)
+}
+
+// HSet queues setting the fields of the hash at key
+func (p *Pipe) HSet(key string, values map[string]interface{}) *redis.IntCmd {
+	return p.pipe.HSet(p.ctx, p.r.getKey(key), values)
+}
+
+// HSetStruct queues storing the `redis` tagged fields of the struct in the hash at key
+func (p *Pipe) HSetStruct(key string, value interface{}) *redis.IntCmd {
+	return p.pipe.HSet(p.ctx, p.r.getKey(key), value)
+}
+
+// HGetAll queues reading every field of the hash at key
+func (p *Pipe) HGetAll(key string) *redis.MapStringStringCmd {
+	return p.pipe.HGetAll(p.ctx, p.r.getKey(key))
+}
+
+// HIncrBy queues adding incr to the field of the hash at key
+func (p *Pipe) HIncrBy(key, field string, incr int64) *redis.IntCmd {
+	return p.pipe.HIncrBy(p.ctx, p.r.getKey(key), field, incr)
+}
+
+// LPush queues prepending the values to the list at key
+func (p *Pipe) LPush(key string, values ...interface{}) *redis.IntCmd {
+	return p.pipe.LPush(p.ctx, p.r.getKey(key), values...)
+}
+
+// RPush queues appending the values to the list at key
+func (p *Pipe) RPush(key string, values ...interface{}) *redis.IntCmd {
+	return p.pipe.RPush(p.ctx, p.r.getKey(key), values...)
+}
+
+// LTrim queues keeping only the elements of the list at key from start to stop included
+func (p *Pipe) LTrim(key string, start, stop int64) *redis.StatusCmd {
+	return p.pipe.LTrim(p.ctx, p.r.getKey(key), start, stop)
+}
+
+// SAdd queues adding the members to the set at key
+func (p *Pipe) SAdd(key string, members ...interface{}) *redis.IntCmd {
+	return p.pipe.SAdd(p.ctx, p.r.getKey(key), members...)
+}
+
+// SRem queues removing the members from the set at key
+func (p *Pipe) SRem(key string, members ...interface{}) *redis.IntCmd {
+	return p.pipe.SRem(p.ctx, p.r.getKey(key), members...)
+}
+
+// ZAdd queues adding the members to the sorted set at key
+func (p *Pipe) ZAdd(key string, members ...ScoredMember) *redis.IntCmd {
+	return p.pipe.ZAdd(p.ctx, p.r.getKey(key), zMembers(members)...)
+}
+
+// ZIncrBy queues adding incr to the score of member of the sorted set at key
+func (p *Pipe) ZIncrBy(key, member string, incr float64) *redis.FloatCmd {
+	return p.pipe.ZIncrBy(p.ctx, p.r.getKey(key), incr, member)
+}
@@ -0,0 +1,124 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_Pipeline(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	r.encoder = Options{Codec: MsgpackCodec}.encoder()
+	require.NoError(t, mr.Set("test:counter", "1"))
+
+	var found, missing menu
+	var foundCmd, missingCmd *DecodeCmd
+	var incr *redis.IntCmd
+	var name *redis.StringCmd
+	var replaced, notReplaced *redis.BoolCmd
+	err := r.Pipeline(ctx, func(p *Pipe) error {
+		p.Set("menu:1", menu{ID: 1, Name: "pizza"}, time.Hour)
+		replaced = p.SetXX("menu:1", menu{ID: 1, Name: "burger"}, time.Minute)
+		notReplaced = p.SetXX("menu:3", menu{ID: 3}, time.Minute)
+		p.SetString("name", "rahim", 0)
+		foundCmd = p.GetStruct("menu:1", &found)
+		missingCmd = p.GetStruct("menu:2", &missing)
+		incr = p.IncrBy("counter", 2)
+		name = p.Get("name")
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, foundCmd.Err())
+	assert.Equal(t, menu{ID: 1, Name: "burger"}, found)
+	assert.ErrorIs(t, missingCmd.Err(), redis.Nil)
+	assert.Equal(t, int64(3), incr.Val())
+	assert.Equal(t, "rahim", name.Val())
+	assert.Equal(t, time.Minute, mr.TTL("test:menu:1"))
+	assert.True(t, replaced.Val())
+	assert.False(t, notReplaced.Val())
+	assert.False(t, mr.Exists("test:menu:3"))
+}
+
+func TestRedis_Pipeline_errors(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	require.NoError(t, mr.Set("test:name", "rahim"))
+
+	// the other replies are decoded when a command fails
+	require.NoError(t, r.SetStructCtx(ctx, "menu:1", menu{ID: 1}, 0))
+	var found menu
+	var foundCmd *DecodeCmd
+	err := r.Pipeline(ctx, func(p *Pipe) error {
+		p.Incr("name")
+		p.SetString("other", "v", 0)
+		foundCmd = p.GetStruct("menu:1", &found)
+		return nil
+	})
+	assert.Error(t, err)
+	assert.True(t, mr.Exists("test:other"))
+	require.NoError(t, foundCmd.Err())
+	assert.Equal(t, 1, found.ID)
+
+	errAbort := errors.New("abort")
+	err = r.Pipeline(ctx, func(p *Pipe) error {
+		p.SetString("aborted", "v", 0)
+		return errAbort
+	})
+	assert.ErrorIs(t, err, errAbort)
+
+	err = r.Pipeline(ctx, func(p *Pipe) error {
+		p.Set("unencodable", func() {}, 0)
+		p.SetString("skipped", "v", 0)
+		return nil
+	})
+	assert.Error(t, err)
+	assert.False(t, mr.Exists("test:aborted"))
+	assert.False(t, mr.Exists("test:skipped"))
+}
+
+func TestRedis_Tx(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	require.NoError(t, mr.Set("test:stock:12", "1"))
+
+	decrement := func(tx *Tx) error {
+		stock, err := tx.GetInt(ctx, "stock:12")
+		if err != nil {
+			return err
+		}
+		if stock == 0 {
+			return errors.New("out of stock")
+		}
+		return tx.Exec(ctx, func(p *Pipe) error {
+			p.IncrBy("stock:12", -1)
+			p.Incr("sold")
+			return nil
+		})
+	}
+	require.NoError(t, r.Tx(ctx, decrement, "stock:12"))
+	assert.EqualError(t, r.Tx(ctx, decrement, "stock:12"), "out of stock")
+	sold, _ := mr.Get("test:sold")
+	assert.Equal(t, "1", sold)
+
+	err := r.Tx(ctx, func(tx *Tx) error {
+		if _, err := tx.GetInt(ctx, "stock:12"); err != nil {
+			return err
+		}
+		// someone else changes the watched key
+		require.NoError(t, r.SetStringCtx(ctx, "stock:12", "5", 0))
+		return tx.Exec(ctx, func(p *Pipe) error {
+			p.Incr("sold")
+			return nil
+		})
+	}, "stock:12")
+	assert.ErrorIs(t, err, redis.TxFailedErr)
+	sold, _ = mr.Get("test:sold")
+	assert.Equal(t, "1", sold)
+}
@@ -0,0 +1,177 @@
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/mostakim64/golang-utils/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	minResubscribeBackoff = 100 * time.Millisecond
+	maxResubscribeBackoff = 5 * time.Second
+)
+
+// Event is a message received by an EventHandler
+type Event[T any] struct {
+	// Channel is the channel the event was published on, without the Prefix
+	Channel string
+	// Pattern is the matching pattern of a PSubscribe, without the Prefix
+	Pattern string
+	Value   T
+}
+
+// EventHandler handles the events of a subscription one at a time, a returned error is logged
+type EventHandler[T any] func(ctx context.Context, event Event[T]) error
+
+// Topic publishes and subscribes to T events on a pub/sub channel
+type Topic[T any] struct {
+	redis   *Redis
+	channel string
+}
+
+// Subscription receives events until closed
+type Subscription struct {
+	pubsub *redis.PubSub
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+/*
+NewTopic returns the Topic on channel, prefixed with the Prefix. Events are encoded with
+the Codec of r. Pub/sub delivers to the subscribers connected at the time only, use a Queue
+for events which must not be lost.
+
+Example:
+
+	configReloaded := redisutil.NewTopic[ConfigChange](redis, "events:config")
+	err := configReloaded.Publish(ctx, ConfigChange{Key: "delivery_fee"})
+*/
+func NewTopic[T any](r *Redis, channel string) *Topic[T] {
+	return &Topic[T]{redis: r, channel: channel}
+}
+
+// Publish sends the event to the current subscribers
+func (t *Topic[T]) Publish(ctx context.Context, value T) error {
+	b, err := t.redis.encoder.encode(value)
+	if err != nil {
+		return err
+	}
+
+	return t.redis.RedisClient.Publish(ctx, t.redis.getKey(t.channel), b).Err()
+}
+
+/*
+Subscribe calls handler for every event of the topic until the Subscription is closed or ctx
+is done. The subscription is active when Subscribe returns, it reconnects and resubscribes
+when the connection is lost. A panic of handler is recovered and logged.
+
+Example:
+
+	sub, err := configReloaded.Subscribe(ctx, func(ctx context.Context, event redisutil.Event[ConfigChange]) error {
+		return config.Reload(ctx, event.Value.Key)
+	})
+	defer sub.Close()
+*/
+func (t *Topic[T]) Subscribe(ctx context.Context, handler EventHandler[T]) (*Subscription, error) {
+	return subscribe(ctx, t.redis, handler, false, t.channel)
+}
+
+// PSubscribe calls handler for the events of every channel matching the patterns, e.g.
+// "events:*", see Topic.Subscribe
+func PSubscribe[T any](ctx context.Context, r *Redis, handler EventHandler[T], patterns ...string) (*Subscription, error) {
+	return subscribe(ctx, r, handler, true, patterns...)
+}
+
+// Close stops receiving events and waits for the handler in progress
+func (s *Subscription) Close() error {
+	s.cancel()
+	// closing the connection unblocks the pending Receive
+	err := s.pubsub.Close()
+	<-s.done
+	return err
+}
+
+func subscribe[T any](ctx context.Context, r *Redis, handler EventHandler[T], pattern bool, names ...string) (*Subscription, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	var pubsub *redis.PubSub
+	if pattern {
+		pubsub = r.RedisClient.PSubscribe(ctx, r.getKeys(names)...)
+	} else {
+		pubsub = r.RedisClient.Subscribe(ctx, r.getKeys(names)...)
+	}
+	// wait for the confirmations so events published after Subscribe returns are received
+	for range names {
+		if _, err := pubsub.Receive(ctx); err != nil {
+			cancel()
+			_ = pubsub.Close()
+			return nil, err
+		}
+	}
+
+	s := &Subscription{pubsub: pubsub, cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		receive(ctx, r, pubsub, handler)
+	}()
+	go func() {
+		// a done parent context closes the subscription too
+		<-ctx.Done()
+		_ = pubsub.Close()
+	}()
+
+	return s, nil
+}
+
+// receive dispatches the events until ctx is done. go-redis reconnects and resubscribes
+// on the next Receive after a failure.
+func receive[T any](ctx context.Context, r *Redis, pubsub *redis.PubSub, handler EventHandler[T]) {
+	backoff := minResubscribeBackoff
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Warn("redisutil: subscription failed, reconnecting in ", backoff, ": ", err)
+			sleepCtx(ctx, backoff)
+			if backoff *= 2; backoff > maxResubscribeBackoff {
+				backoff = maxResubscribeBackoff
+			}
+			continue
+		}
+		backoff = minResubscribeBackoff
+
+		m, ok := msg.(*redis.Message)
+		if !ok {
+			continue
+		}
+
+		event := Event[T]{
+			Channel: strings.TrimPrefix(m.Channel, r.Prefix),
+			Pattern: strings.TrimPrefix(m.Pattern, r.Prefix),
+		}
+		if err := r.encoder.decode([]byte(m.Payload), &event.Value); err != nil {
+			logger.Warn("redisutil: failed to decode event of ", m.Channel, ": ", err)
+			continue
+		}
+		if err := callEventHandler(ctx, event, handler); err != nil {
+			logger.Error("redisutil: event handler of ", m.Channel, " failed: ", err)
+		}
+	}
+}
+
+// callEventHandler turns a panic of the handler into an error with the stack
+func callEventHandler[T any](ctx context.Context, event Event[T], handler EventHandler[T]) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v\n%s", p, debug.Stack())
+		}
+	}()
+	return handler(ctx, event)
+}
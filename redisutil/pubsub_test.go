@@ -0,0 +1,131 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// events collects the events received by a subscription
+type events[T any] struct {
+	mu     sync.Mutex
+	events []Event[T]
+}
+
+func (e *events[T]) handle(ctx context.Context, event Event[T]) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+	return nil
+}
+
+func (e *events[T]) get() []Event[T] {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Event[T]{}, e.events...)
+}
+
+func TestTopic_Subscribe(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	topic := NewTopic[menu](r, "events:menu")
+
+	var received events[menu]
+	sub, err := topic.Subscribe(ctx, received.handle)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	assert.Equal(t, []string{"test:events:menu"}, mr.PubSubChannels(""))
+	require.NoError(t, topic.Publish(ctx, menu{ID: 1, Name: "Breakfast"}))
+
+	assert.Eventually(t, func() bool { return len(received.get()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, Event[menu]{Channel: "events:menu", Value: menu{ID: 1, Name: "Breakfast"}}, received.get()[0])
+}
+
+func TestPSubscribe(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+
+	var received events[menu]
+	sub, err := PSubscribe[menu](ctx, r, received.handle, "events:*")
+	require.NoError(t, err)
+	defer sub.Close()
+
+	require.NoError(t, NewTopic[menu](r, "events:menu").Publish(ctx, menu{ID: 1}))
+	require.NoError(t, NewTopic[menu](r, "events:lunch").Publish(ctx, menu{ID: 2}))
+
+	assert.Eventually(t, func() bool { return len(received.get()) == 2 }, time.Second, 10*time.Millisecond)
+	got := received.get()
+	assert.Equal(t, Event[menu]{Channel: "events:menu", Pattern: "events:*", Value: menu{ID: 1}}, got[0])
+	assert.Equal(t, Event[menu]{Channel: "events:lunch", Pattern: "events:*", Value: menu{ID: 2}}, got[1])
+}
+
+func TestSubscription_handler_panic_and_bad_payload(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	topic := NewTopic[menu](r, "events:menu")
+
+	var received events[menu]
+	sub, err := topic.Subscribe(ctx, func(ctx context.Context, event Event[menu]) error {
+		switch event.Value.ID {
+		case 1:
+			panic("boom")
+		case 2:
+			return errors.New("failed")
+		}
+		return received.handle(ctx, event)
+	})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	require.NoError(t, topic.Publish(ctx, menu{ID: 1}))
+	mr.Publish("test:events:menu", "not json")
+	require.NoError(t, topic.Publish(ctx, menu{ID: 2}))
+	require.NoError(t, topic.Publish(ctx, menu{ID: 3}))
+
+	// the subscription survives the panic, the error and the undecodable payload
+	assert.Eventually(t, func() bool { return len(received.get()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 3, received.get()[0].Value.ID)
+}
+
+func TestSubscription_resubscribes(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	topic := NewTopic[menu](r, "events:menu")
+
+	var received events[menu]
+	sub, err := topic.Subscribe(ctx, received.handle)
+	require.NoError(t, err)
+	defer sub.Close()
+
+	mr.Restart()
+	assert.Eventually(t, func() bool {
+		return len(mr.PubSubChannels("")) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, topic.Publish(ctx, menu{ID: 1}))
+	assert.Eventually(t, func() bool { return len(received.get()) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestSubscription_Close(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r, mr := newTestRedis(t)
+	topic := NewTopic[menu](r, "events:menu")
+
+	sub, err := topic.Subscribe(ctx, func(ctx context.Context, event Event[menu]) error { return nil })
+	require.NoError(t, err)
+	require.NoError(t, sub.Close())
+	assert.Eventually(t, func() bool { return len(mr.PubSubChannels("")) == 0 }, time.Second, 10*time.Millisecond)
+
+	// a done context closes the subscription too
+	sub, err = topic.Subscribe(ctx, func(ctx context.Context, event Event[menu]) error { return nil })
+	require.NoError(t, err)
+	cancel()
+	assert.Eventually(t, func() bool { return len(mr.PubSubChannels("")) == 0 }, time.Second, 10*time.Millisecond)
+	_ = sub.Close()
+}
@@ -0,0 +1,519 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mostakim64/golang-utils/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultConsumerGroup = "workers"
+	defaultBlock         = 2 * time.Second
+	defaultJobRetry      = 30 * time.Second
+	defaultMaxJobRetry   = 10 * time.Minute
+	defaultMaxDeliveries = 5
+	deadLetterSuffix     = ":dead"
+	// reclaimScan is the number of pending jobs checked per XPENDING by reclaim
+	reclaimScan = 100
+)
+
+var errMaxDeliveries = errors.New("redisutil: job exceeded the max deliveries")
+
+// renewClaimScript resets the idle time of a job only while it is still pending for the
+// consumer, so a job reclaimed by another consumer isn't taken back. JUSTID and RETRYCOUNT
+// keep the delivery count of the job.
+// KEYS[1] stream, ARGV group, consumer, job id, delivery count. Returns 1 when renewed.
+var renewClaimScript = redis.NewScript(`
+local pending = redis.call("XPENDING", KEYS[1], ARGV[1], ARGV[3], ARGV[3], 1, ARGV[2])
+if #pending == 0 then
+	return 0
+end
+redis.call("XCLAIM", KEYS[1], ARGV[1], ARGV[2], 0, ARGV[3], "RETRYCOUNT", ARGV[4], "JUSTID")
+return 1
+`)
+
+// QueueOptions configures a Queue
+type QueueOptions struct {
+	// MaxLen trims the stream to about MaxLen entries on Enqueue, 0 keeps every entry.
+	// Acknowledged jobs stay in the stream until trimmed.
+	MaxLen int64
+}
+
+// ConsumerOptions configures Queue.Consume
+type ConsumerOptions struct {
+	// Group is the consumer group, every job is handled by one consumer of the group, "workers" by default
+	Group string
+	// Consumer names this consumer in the group, hostname and a random suffix by default
+	Consumer string
+	// Concurrency is the number of jobs handled at the same time, 1 by default
+	Concurrency int
+	// Block is how long a read waits for new jobs, it bounds the shutdown time, 2s by default
+	Block time.Duration
+	// RetryBackoff is how long a failed or abandoned job stays pending before it is reclaimed
+	// and retried by a consumer of the group, 30s by default. It doubles on every delivery of
+	// the job up to MaxRetryBackoff, 10 minutes by default.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// MaxDeliveries moves a job to the dead letter stream after that many failed deliveries, 5 by default
+	MaxDeliveries int64
+	// PollInterval is how often the due delayed jobs are enqueued, 1s by default
+	PollInterval time.Duration
+}
+
+// Job is a job delivered to a JobHandler
+type Job[T any] struct {
+	ID    string
+	Value T
+	// Attempt is the delivery count of the job, 1 on the first delivery
+	Attempt int64
+
+	payload interface{}
+}
+
+// DeadLetter is a job which failed MaxDeliveries times or couldn't be decoded
+type DeadLetter[T any] struct {
+	Job[T]
+	Error string
+}
+
+// JobHandler handles a job, the job is retried when an error is returned
+type JobHandler[T any] func(ctx context.Context, job Job[T]) error
+
+// Queue is a durable work queue of T values on a redis stream
+type Queue[T any] struct {
+	redis  *Redis
+	name   string
+	stream string
+	opts   QueueOptions
+}
+
+/*
+NewQueue returns the Queue on the stream name, the dead letters go to the stream name:dead.
+
+Example:
+
+	emails := redisutil.NewQueue[Email](redis, "jobs:email", redisutil.QueueOptions{MaxLen: 100000})
+	_, err := emails.Enqueue(ctx, Email{To: "rahim@example.com"})
+*/
+func NewQueue[T any](r *Redis, name string, opts QueueOptions) *Queue[T] {
+	return &Queue[T]{redis: r, name: name, stream: r.getKey(name), opts: opts}
+}
+
+// Enqueue adds the job and returns its id
+func (q *Queue[T]) Enqueue(ctx context.Context, value T) (string, error) {
+	b, err := q.redis.encoder.encode(value)
+	if err != nil {
+		return "", err
+	}
+
+	return q.redis.RedisClient.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.stream,
+		MaxLen: q.opts.MaxLen,
+		Approx: q.opts.MaxLen > 0,
+		Values: map[string]interface{}{"payload": b},
+	}).Result()
+}
+
+/*
+Consume handles the jobs with handler until ctx is done, then waits for the jobs in progress
+and returns. A job is acknowledged when handler returns nil, otherwise it stays pending and is
+retried after opts.RetryBackoff, doubled on every further attempt. A job abandoned by a crashed
+consumer is retried the same way. The claim of a job is renewed while it is handled, so a slow
+job isn't retried by another consumer in the meantime. The handler gets a context which is not
+cancelled on shutdown.
+
+The jobs due for a retry are found with XPENDING and claimed one by one with XCLAIM rather
+than with XAUTOCLAIM, since XAUTOCLAIM takes a single min idle time while the retry delay of
+each job depends on its delivery count.
+
+Example:
+
+	err := emails.Consume(ctx, func(ctx context.Context, job redisutil.Job[Email]) error {
+		return mailer.Send(ctx, job.Value)
+	}, redisutil.ConsumerOptions{Concurrency: 10})
+*/
+func (q *Queue[T]) Consume(ctx context.Context, handler JobHandler[T], opts ConsumerOptions) error {
+	o := opts.withDefaults()
+	if err := q.createGroup(ctx, o.Group); err != nil {
+		return err
+	}
+
+	jobs := make(chan Job[T])
+	var wg sync.WaitGroup
+	for i := 0; i < o.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				q.handle(detach(ctx), job, handler, o)
+			}
+		}()
+	}
+	promoteCtx, stopPromote := context.WithCancel(ctx)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.promoteLoop(promoteCtx, o.PollInterval)
+	}()
+	defer func() {
+		stopPromote()
+		close(jobs)
+		wg.Wait()
+	}()
+
+	// reads use a detached context, they are bounded by Block and ctx is checked in between
+	readCtx := detach(ctx)
+	claimStart := "0-0"
+	lastClaim := time.Time{}
+	for ctx.Err() == nil {
+		var batch []Job[T]
+		if time.Since(lastClaim) >= o.RetryBackoff/2 {
+			lastClaim = time.Now()
+			claimed, next, err := q.reclaim(readCtx, claimStart, o)
+			if err != nil {
+				logger.Warn("redisutil: failed to reclaim jobs of ", q.stream, ": ", err)
+			}
+			batch, claimStart = claimed, next
+			if claimStart != "0-0" {
+				// more pending jobs to scan, claim again on the next round
+				lastClaim = time.Time{}
+			}
+		}
+
+		if len(batch) == 0 {
+			read, err := q.read(readCtx, o)
+			if err != nil {
+				logger.Warn("redisutil: failed to read jobs of ", q.stream, ": ", err)
+				sleepCtx(ctx, time.Second)
+				continue
+			}
+			batch = read
+		}
+
+		for _, job := range batch {
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				// left pending, reclaimed by another consumer after RetryBackoff
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// DeadLetters returns up to count of the oldest dead letters
+func (q *Queue[T]) DeadLetters(ctx context.Context, count int64) ([]DeadLetter[T], error) {
+	msgs, err := q.redis.RedisClient.XRangeN(ctx, q.stream+deadLetterSuffix, "-", "+", count).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]DeadLetter[T], 0, len(msgs))
+	for _, msg := range msgs {
+		attempt, _ := strconv.ParseInt(fmt.Sprint(msg.Values["deliveries"]), 10, 64)
+		letter := DeadLetter[T]{
+			Job:   Job[T]{ID: fmt.Sprint(msg.Values["id"]), Attempt: attempt},
+			Error: fmt.Sprint(msg.Values["error"]),
+		}
+		if err := q.decode(msg, &letter.Value); err != nil {
+			letter.Error += "; " + err.Error()
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+func (q *Queue[T]) createGroup(ctx context.Context, group string) error {
+	err := q.redis.RedisClient.XGroupCreateMkStream(ctx, q.stream, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// read returns the new jobs, waiting up to Block for them
+func (q *Queue[T]) read(ctx context.Context, o ConsumerOptions) ([]Job[T], error) {
+	streams, err := q.redis.RedisClient.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    o.Group,
+		Consumer: o.Consumer,
+		Streams:  []string{q.stream, ">"},
+		Count:    int64(o.Concurrency),
+		Block:    o.Block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []Job[T]
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			if job, ok := q.job(ctx, msg, 1, o); ok {
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	return jobs, nil
+}
+
+// reclaim takes over the jobs due for a retry, pending for longer than the retry delay of
+// their last delivery, and dead letters the ones delivered more than MaxDeliveries times.
+// The pending jobs are scanned from start, the returned id is where the next scan starts.
+func (q *Queue[T]) reclaim(ctx context.Context, start string, o ConsumerOptions) ([]Job[T], string, error) {
+	pending, err := q.redis.RedisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: q.stream,
+		Group:  o.Group,
+		Idle:   o.RetryBackoff,
+		Start:  start,
+		End:    "+",
+		Count:  reclaimScan,
+	}).Result()
+	if err != nil {
+		return nil, "0-0", err
+	}
+
+	var due []redis.XPendingExt
+	scanned := len(pending)
+	for i, entry := range pending {
+		if entry.Idle >= o.retryDelay(entry.RetryCount) {
+			due = append(due, entry)
+			if len(due) == o.Concurrency {
+				scanned = i + 1
+				break
+			}
+		}
+	}
+	next := "0-0"
+	if scanned < len(pending) || len(pending) == reclaimScan {
+		next = nextStreamID(pending[scanned-1].ID)
+	}
+	if len(due) == 0 {
+		return nil, next, nil
+	}
+
+	// the idle time is checked again by XCLAIM, another consumer may have claimed the job since
+	cmds := make([]*redis.XMessageSliceCmd, len(due))
+	_, err = q.redis.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, entry := range due {
+			cmds[i] = pipe.XClaim(ctx, &redis.XClaimArgs{
+				Stream:   q.stream,
+				Group:    o.Group,
+				Consumer: o.Consumer,
+				MinIdle:  o.retryDelay(entry.RetryCount),
+				Messages: []string{entry.ID},
+			})
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, next, err
+	}
+
+	var jobs []Job[T]
+	for i, entry := range due {
+		msgs := cmds[i].Val()
+		if len(msgs) == 0 {
+			continue
+		}
+		attempt := entry.RetryCount + 1
+		if attempt > o.MaxDeliveries {
+			q.deadLetter(ctx, entry.ID, msgs[0].Values["payload"], attempt, errMaxDeliveries, o)
+			continue
+		}
+		if job, ok := q.job(ctx, msgs[0], attempt, o); ok {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, next, nil
+}
+
+// job decodes the message, a message which can't be decoded is dead lettered
+func (q *Queue[T]) job(ctx context.Context, msg redis.XMessage, attempt int64, o ConsumerOptions) (Job[T], bool) {
+	job := Job[T]{ID: msg.ID, Attempt: attempt, payload: msg.Values["payload"]}
+	if err := q.decode(msg, &job.Value); err != nil {
+		q.deadLetter(ctx, msg.ID, msg.Values["payload"], attempt, err, o)
+		return job, false
+	}
+	return job, true
+}
+
+func (q *Queue[T]) decode(msg redis.XMessage, value *T) error {
+	payload, ok := msg.Values["payload"].(string)
+	if !ok {
+		return fmt.Errorf("redisutil: job %s has no payload", msg.ID)
+	}
+	return q.redis.encoder.decode([]byte(payload), value)
+}
+
+func (q *Queue[T]) handle(ctx context.Context, job Job[T], handler JobHandler[T], o ConsumerOptions) {
+	stopRenewal := q.renewClaim(ctx, job, o)
+	err := callHandler(ctx, job, handler)
+	stopRenewal()
+	if err == nil {
+		if err := q.redis.RedisClient.XAck(ctx, q.stream, o.Group, job.ID).Err(); err != nil {
+			logger.Warn("redisutil: failed to ack job ", job.ID, " of ", q.stream, ": ", err)
+		}
+		return
+	}
+
+	logger.Warn("redisutil: job ", job.ID, " of ", q.stream, " failed on attempt ", job.Attempt, ": ", err)
+	if job.Attempt >= o.MaxDeliveries {
+		q.deadLetter(ctx, job.ID, job.payload, job.Attempt, err, o)
+	}
+}
+
+// renewClaim resets the idle time of the job every third of RetryBackoff until the returned
+// func is called, so the job isn't reclaimed while it is handled. The renewal stops once the
+// job was reclaimed by another consumer, or after failing for RetryBackoff since by then
+// another consumer may have reclaimed it.
+func (q *Queue[T]) renewClaim(ctx context.Context, job Job[T], o ConsumerOptions) func() {
+	interval := o.RetryBackoff / 3
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		lastRenewal := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			renewed, err := renewClaimScript.Run(ctx, q.redis.RedisClient, []string{q.stream}, o.Group, o.Consumer, job.ID, job.Attempt).Bool()
+			switch {
+			case err == nil && !renewed:
+				logger.Warn("redisutil: job ", job.ID, " of ", q.stream, " was reclaimed by another consumer while it was handled")
+				return
+			case err == nil:
+				lastRenewal = time.Now()
+			case ctx.Err() != nil:
+				return
+			case time.Since(lastRenewal) >= o.RetryBackoff:
+				logger.Warn("redisutil: lost the claim of job ", job.ID, " of ", q.stream, ": ", err)
+				return
+			default:
+				logger.Warn("redisutil: failed to renew the claim of job ", job.ID, " of ", q.stream, ", retrying: ", err)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// callHandler turns a panic of the handler into an error
+func callHandler[T any](ctx context.Context, job Job[T], handler JobHandler[T]) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("redisutil: job handler panicked: %v", p)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// deadLetter moves the job to the dead letter stream and acknowledges it
+func (q *Queue[T]) deadLetter(ctx context.Context, id string, payload interface{}, deliveries int64, cause error, o ConsumerOptions) {
+	logger.Error("redisutil: job ", id, " of ", q.stream, " moved to dead letters: ", cause)
+	_, err := q.redis.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: q.stream + deadLetterSuffix,
+			Values: map[string]interface{}{
+				"payload":    payload,
+				"id":         id,
+				"deliveries": deliveries,
+				"error":      cause.Error(),
+			},
+		})
+		pipe.XAck(ctx, q.stream, o.Group, id)
+		return nil
+	})
+	if err != nil {
+		logger.Warn("redisutil: failed to dead letter job ", id, " of ", q.stream, ": ", err)
+	}
+}
+
+func (o ConsumerOptions) withDefaults() ConsumerOptions {
+	if o.Group == "" {
+		o.Group = defaultConsumerGroup
+	}
+	if o.Consumer == "" {
+		hostname, _ := os.Hostname()
+		o.Consumer = hostname + "-" + randomID()[:8]
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+	if o.Block <= 0 {
+		o.Block = defaultBlock
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = defaultJobRetry
+	}
+	if o.MaxRetryBackoff <= 0 {
+		o.MaxRetryBackoff = defaultMaxJobRetry
+	}
+	if o.MaxRetryBackoff < o.RetryBackoff {
+		o.MaxRetryBackoff = o.RetryBackoff
+	}
+	if o.MaxDeliveries <= 0 {
+		o.MaxDeliveries = defaultMaxDeliveries
+	}
+	if o.PollInterval <= 0 {
+		o.PollInterval = defaultPollInterval
+	}
+	return o
+}
+
+// retryDelay is how long a job stays pending after its nth delivery before it is retried
+func (o ConsumerOptions) retryDelay(deliveries int64) time.Duration {
+	delay := o.RetryBackoff
+	for i := int64(1); i < deliveries && delay < o.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > o.MaxRetryBackoff {
+		return o.MaxRetryBackoff
+	}
+	return delay
+}
+
+// nextStreamID returns the stream id following id, an exclusive start for XPENDING
+func nextStreamID(id string) string {
+	ms, seq, ok := strings.Cut(id, "-")
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if !ok || err != nil {
+		return id
+	}
+	return ms + "-" + strconv.FormatUint(n+1, 10)
+}
+
+// sleepCtx waits for d or until ctx is done
+func sleepCtx(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
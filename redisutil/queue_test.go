@@ -0,0 +1,292 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type email struct {
+	To string `json:"to"`
+}
+
+// consume runs Consume in the background and returns the func stopping it
+func consume[T any](t *testing.T, q *Queue[T], handler JobHandler[T], opts ConsumerOptions) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- q.Consume(ctx, handler, opts) }()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			cancel()
+			assert.NoError(t, <-done)
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	q := NewQueue[email](r, "jobs:email", QueueOptions{})
+
+	var mu sync.Mutex
+	var got []string
+	stop := consume(t, q, func(ctx context.Context, job Job[email]) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, job.Value.To)
+		assert.Equal(t, int64(1), job.Attempt)
+		return nil
+	}, ConsumerOptions{Concurrency: 3, Block: 20 * time.Millisecond})
+
+	for _, to := range []string{"a", "b", "c", "d", "e"} {
+		_, err := q.Enqueue(ctx, email{To: to})
+		require.NoError(t, err)
+	}
+	assert.True(t, mr.Exists("test:jobs:email"))
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 5
+	}, time.Second, 10*time.Millisecond)
+	stop()
+
+	sort.Strings(got)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, got)
+	pending, err := r.RedisClient.XPending(ctx, "test:jobs:email", defaultConsumerGroup).Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count)
+}
+
+func TestQueue_retry(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	q := NewQueue[email](r, "jobs:email", QueueOptions{})
+	_, err := q.Enqueue(ctx, email{To: "a"})
+	require.NoError(t, err)
+
+	attempts := make(chan int64, 10)
+	consume(t, q, func(ctx context.Context, job Job[email]) error {
+		attempts <- job.Attempt
+		if job.Attempt == 1 {
+			return errors.New("smtp unavailable")
+		}
+		return nil
+	}, ConsumerOptions{Block: 20 * time.Millisecond, RetryBackoff: 50 * time.Millisecond})
+
+	assert.Equal(t, int64(1), <-attempts)
+	select {
+	case attempt := <-attempts:
+		assert.Equal(t, int64(2), attempt)
+	case <-time.After(2 * time.Second):
+		t.Fatal("job was not retried")
+	}
+}
+
+func TestConsumerOptions_retryDelay(t *testing.T) {
+	o := ConsumerOptions{RetryBackoff: time.Second, MaxRetryBackoff: 5 * time.Second}.withDefaults()
+	for deliveries, want := range map[int64]time.Duration{0: time.Second, 1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 60: 5 * time.Second} {
+		assert.Equal(t, want, o.retryDelay(deliveries), "deliveries %d", deliveries)
+	}
+
+	o = ConsumerOptions{RetryBackoff: time.Hour}.withDefaults()
+	assert.Equal(t, time.Hour, o.MaxRetryBackoff)
+	assert.Equal(t, "1700000000000-4", nextStreamID("1700000000000-3"))
+}
+
+func TestQueue_retry_backoff(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	q := NewQueue[email](r, "jobs:email", QueueOptions{})
+	_, err := q.Enqueue(ctx, email{To: "a"})
+	require.NoError(t, err)
+
+	attempts := make(chan time.Time, 10)
+	consume(t, q, func(ctx context.Context, job Job[email]) error {
+		attempts <- time.Now()
+		return errors.New("smtp unavailable")
+	}, ConsumerOptions{Block: 10 * time.Millisecond, RetryBackoff: 40 * time.Millisecond, MaxDeliveries: 10})
+
+	var at []time.Time
+	for len(at) < 4 {
+		select {
+		case a := <-attempts:
+			at = append(at, a)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("job was retried %d times", len(at)-1)
+		}
+	}
+	// the delay doubles on every attempt
+	for i, delay := range []time.Duration{40, 80, 160} {
+		assert.GreaterOrEqual(t, at[i+1].Sub(at[i]), delay*time.Millisecond, "retry %d", i+1)
+	}
+}
+
+func TestQueue_renews_claim_of_slow_jobs(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	q := NewQueue[email](r, "jobs:email", QueueOptions{})
+	_, err := q.Enqueue(ctx, email{To: "a"})
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var attempts []int64
+	done := make(chan struct{})
+	// a free worker reclaims every 30ms, the job takes much longer than RetryBackoff
+	consume(t, q, func(ctx context.Context, job Job[email]) error {
+		mu.Lock()
+		attempts = append(attempts, job.Attempt)
+		mu.Unlock()
+		time.Sleep(300 * time.Millisecond)
+		close(done)
+		return nil
+	}, ConsumerOptions{Concurrency: 2, Block: 10 * time.Millisecond, RetryBackoff: 60 * time.Millisecond})
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("job was not handled")
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []int64{1}, attempts)
+	assert.Eventually(t, func() bool {
+		pending, err := r.RedisClient.XPending(ctx, "test:jobs:email", defaultConsumerGroup).Result()
+		return err == nil && pending.Count == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestQueue_renewal_stops_once_reclaimed(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	q := NewQueue[email](r, "jobs:email", QueueOptions{})
+	_, err := q.Enqueue(ctx, email{To: "a"})
+	require.NoError(t, err)
+
+	o := ConsumerOptions{Consumer: "slow", RetryBackoff: 30 * time.Millisecond}.withDefaults()
+	require.NoError(t, q.createGroup(ctx, o.Group))
+	jobs, err := q.read(ctx, o)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+
+	owner := func() string {
+		pending, err := r.RedisClient.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream: "test:jobs:email", Group: o.Group, Start: "-", End: "+", Count: 1,
+		}).Result()
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		return pending[0].Consumer
+	}
+
+	stop := q.renewClaim(ctx, jobs[0], o)
+	defer stop()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "slow", owner())
+
+	// another consumer reclaims the job, the renewal must not take it back
+	err = r.RedisClient.XClaim(ctx, &redis.XClaimArgs{
+		Stream: "test:jobs:email", Group: o.Group, Consumer: "other", Messages: []string{jobs[0].ID},
+	}).Err()
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "other", owner())
+}
+
+func TestQueue_dead_letter(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	q := NewQueue[email](r, "jobs:email", QueueOptions{})
+	id, err := q.Enqueue(ctx, email{To: "a"})
+	require.NoError(t, err)
+	_, err = mr.XAdd("test:jobs:email", "*", []string{"payload", "not json"})
+	require.NoError(t, err)
+
+	consume(t, q, func(ctx context.Context, job Job[email]) error {
+		panic("bug")
+	}, ConsumerOptions{Block: 20 * time.Millisecond, RetryBackoff: 50 * time.Millisecond, MaxDeliveries: 2})
+
+	var letters []DeadLetter[email]
+	assert.Eventually(t, func() bool {
+		letters, err = q.DeadLetters(ctx, 10)
+		return err == nil && len(letters) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, int64(1), letters[0].Attempt)
+	assert.Contains(t, letters[0].Error, "invalid character")
+	assert.Equal(t, id, letters[1].ID)
+	assert.Equal(t, "a", letters[1].Value.To)
+	assert.Equal(t, int64(2), letters[1].Attempt)
+	assert.Contains(t, letters[1].Error, "panicked: bug")
+}
+
+func TestQueue_reclaims_abandoned_jobs(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	q := NewQueue[email](r, "jobs:email", QueueOptions{})
+	_, err := q.Enqueue(ctx, email{To: "a"})
+	require.NoError(t, err)
+
+	// a consumer reads the job and crashes
+	o := ConsumerOptions{Consumer: "crashed", Block: 20 * time.Millisecond}.withDefaults()
+	require.NoError(t, q.createGroup(ctx, o.Group))
+	jobs, err := q.read(ctx, o)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+
+	handled := make(chan Job[email], 1)
+	consume(t, q, func(ctx context.Context, job Job[email]) error {
+		handled <- job
+		return nil
+	}, ConsumerOptions{Block: 20 * time.Millisecond, RetryBackoff: 50 * time.Millisecond})
+
+	select {
+	case job := <-handled:
+		assert.Equal(t, "a", job.Value.To)
+		assert.Equal(t, int64(2), job.Attempt)
+	case <-time.After(2 * time.Second):
+		t.Fatal("abandoned job was not reclaimed")
+	}
+}
+
+func TestQueue_graceful_shutdown(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	q := NewQueue[email](r, "jobs:email", QueueOptions{MaxLen: 100})
+	_, err := q.Enqueue(ctx, email{To: "a"})
+	require.NoError(t, err)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var finished bool
+	stop := consume(t, q, func(ctx context.Context, job Job[email]) error {
+		close(started)
+		<-release
+		assert.NoError(t, ctx.Err())
+		finished = true
+		return nil
+	}, ConsumerOptions{Block: 20 * time.Millisecond})
+
+	<-started
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	stop()
+	assert.True(t, finished)
+
+	pending, err := r.RedisClient.XPending(ctx, "test:jobs:email", defaultConsumerGroup).Result()
+	require.NoError(t, err)
+	assert.Zero(t, pending.Count)
+}
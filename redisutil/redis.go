@@ -1,165 +1,237 @@
 package redisutil
 
 import (
-	"encoding/json"
-	"github.com/go-redis/redis"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+
 	"github.com/mostakim64/golang-utils/logger"
 	utils "github.com/mostakim64/golang-utils/methods"
 	"github.com/mostakim64/golang-utils/redisutil/errutil"
-	"strconv"
-	"time"
+	"github.com/redis/go-redis/v9"
 )
 
 type Redis struct {
-	Prefix      string
-	RedisClient *redis.Client
+	Prefix string
+	// RedisClient is a *redis.Client, a sentinel backed failover *redis.Client
+	// or a *redis.ClusterClient depending on the constructor
+	RedisClient redis.UniversalClient
+
+	encoder encoder
+	metrics *metrics
+	// now is the clock of the fixed and rolling windows, replaced by tests
+	now func() time.Time
 }
 
 /*
 Connect method takes the redis credentials and prefix as input. It's then
-connect to redis instance and return Redis util object otherwise create panic
+connect to redis instance and return Redis util object otherwise create panic.
+
+Use New to configure the connection and get an error instead of a panic.
 */
 func Connect(host, port, pass string, db int, prefix string) *Redis {
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     host + ":" + port,
+	r, err := New(Options{
+		Host:     host,
+		Port:     port,
 		Password: pass,
 		DB:       db,
+		Prefix:   prefix,
 	})
-	logger.Info("connecting to redis at ", host, ":", port, "...")
-	if _, err := redisClient.Ping().Result(); err != nil {
+	if err != nil {
 		logger.Error("failed to connect redis: ", err)
 		panic(err)
 	}
-	logger.Info("redis connection successful...")
-	return &Redis{
-		RedisClient: redisClient,
-		Prefix:      prefix,
-	}
-
+	return r
 }
 
+// Deprecated: use SetCtx
 func (r *Redis) Set(key string, value interface{}, ttl int) error {
+	return r.SetCtx(context.Background(), key, value, ttl)
+}
+
+// SetCtx stores the value encoded with the configured Codec, json by default, with ttl in seconds
+func (r *Redis) SetCtx(ctx context.Context, key string, value interface{}, ttl int) error {
 	key = r.getKey(key)
 	if utils.IsEmpty(key) || utils.IsEmpty(value) {
 		return errutil.ErrEmptyRedisKeyValue
 	}
 
-	serializedValue, err := json.Marshal(value)
+	serializedValue, err := r.encoder.encode(value)
 	if err != nil {
 		return err
 	}
 
-	return r.RedisClient.Set(key, string(serializedValue), time.Duration(ttl)*time.Second).Err()
+	return r.RedisClient.Set(ctx, key, serializedValue, time.Duration(ttl)*time.Second).Err()
 }
 
+// Deprecated: use SetStringCtx
 func (r *Redis) SetString(key string, value string, ttl int) error {
+	return r.SetStringCtx(context.Background(), key, value, ttl)
+}
+
+// SetStringCtx stores the value as is with ttl in seconds
+func (r *Redis) SetStringCtx(ctx context.Context, key string, value string, ttl int) error {
 	key = r.getKey(key)
 	if utils.IsEmpty(key) || utils.IsEmpty(value) {
 		return errutil.ErrEmptyRedisKeyValue
 	}
 
-	return r.RedisClient.Set(key, value, time.Duration(ttl)*time.Second).Err()
+	return r.RedisClient.Set(ctx, key, value, time.Duration(ttl)*time.Second).Err()
 }
 
+// Deprecated: use SetStructCtx
 func (r *Redis) SetStruct(key string, value interface{}, ttl time.Duration) error {
+	return r.SetStructCtx(context.Background(), key, value, ttl)
+}
+
+// SetStructCtx stores the value encoded with the configured Codec, json by default, ttl is in seconds
+func (r *Redis) SetStructCtx(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
 	key = r.getKey(key)
-	serializedValue, err := json.Marshal(value)
+	serializedValue, err := r.encoder.encode(value)
 	if err != nil {
 		return err
 	}
 
-	return r.RedisClient.Set(key, string(serializedValue), ttl*time.Second).Err()
+	return r.RedisClient.Set(ctx, key, serializedValue, ttl*time.Second).Err()
 }
 
+// Deprecated: use GetCtx
 func (r *Redis) Get(key string) (string, error) {
+	return r.GetCtx(context.Background(), key)
+}
+
+func (r *Redis) GetCtx(ctx context.Context, key string) (string, error) {
 	key = r.getKey(key)
 	if utils.IsEmpty(key) {
 		return "", errutil.ErrEmptyRedisKeyValue
 	}
 
-	return r.RedisClient.Get(key).Result()
+	return r.RedisClient.Get(ctx, key).Result()
 }
 
+// Deprecated: use GetIntCtx
 func (r *Redis) GetInt(key string) (int, error) {
+	return r.GetIntCtx(context.Background(), key)
+}
+
+func (r *Redis) GetIntCtx(ctx context.Context, key string) (int, error) {
 	key = r.getKey(key)
 	if utils.IsEmpty(key) {
 		return 0, errutil.ErrEmptyRedisKeyValue
 	}
 
-	str, err := r.RedisClient.Get(key).Result()
+	str, err := r.RedisClient.Get(ctx, key).Result()
 	if err != nil {
 		return 0, err
 	}
 
 	return strconv.Atoi(str)
 }
+
+// Deprecated: use GetStructCtx
 func (r *Redis) GetStruct(key string, outputStruct interface{}) error {
+	return r.GetStructCtx(context.Background(), key, outputStruct)
+}
+
+// GetStructCtx decodes the value into outputStruct, whichever Codec it was written with
+func (r *Redis) GetStructCtx(ctx context.Context, key string, outputStruct interface{}) error {
 	key = r.getKey(key)
 	if utils.IsEmpty(key) {
 		return errutil.ErrEmptyRedisKeyValue
 	}
 
-	serializedValue, err := r.RedisClient.Get(key).Result()
+	serializedValue, err := r.RedisClient.Get(ctx, key).Bytes()
 	if err != nil {
 		return err
 	}
 
-	if err := json.Unmarshal([]byte(serializedValue), &outputStruct); err != nil {
-		return err
-	}
-
-	return nil
+	return r.encoder.decode(serializedValue, outputStruct)
 }
 
+// Deprecated: use HasKeyCtx
 func (r *Redis) HasKey(key string) bool {
+	return r.HasKeyCtx(context.Background(), key)
+}
+
+func (r *Redis) HasKeyCtx(ctx context.Context, key string) bool {
 	key = r.getKey(key)
-	exists, err := r.RedisClient.Exists(key).Result()
+	exists, err := r.RedisClient.Exists(ctx, key).Result()
 	if err != nil {
 		return false
 	}
 
 	return exists == 1
 }
+
+// Deprecated: use ExistsCtx
 func (r *Redis) Exists(key string) bool {
-	return r.HasKey(key)
+	return r.HasKeyCtx(context.Background(), key)
+}
+
+func (r *Redis) ExistsCtx(ctx context.Context, key string) bool {
+	return r.HasKeyCtx(ctx, key)
 }
 
+// Deprecated: use IncByCtx
 func (r *Redis) IncBy(key string, value int) error {
+	_, err := r.IncByCtx(context.Background(), key, value)
+	return err
+}
+
+// IncByCtx adds value to the counter at key and returns the new value, value can be negative
+func (r *Redis) IncByCtx(ctx context.Context, key string, value int) (int64, error) {
 	key = r.getKey(key)
-	return r.RedisClient.IncrBy(key, int64(value)).Err()
+	return r.RedisClient.IncrBy(ctx, key, int64(value)).Result()
 }
+
+// Deprecated: use INCRCtx
 func (r *Redis) INCR(key string) error {
+	_, err := r.INCRCtx(context.Background(), key)
+	return err
+}
+
+// INCRCtx adds 1 to the counter at key and returns the new value
+func (r *Redis) INCRCtx(ctx context.Context, key string) (int64, error) {
 	key = r.getKey(key)
-	return r.RedisClient.Incr(key).Err()
+	return r.RedisClient.Incr(ctx, key).Result()
 }
 
+// Deprecated: use DelCtx
 func (r *Redis) Del(keys ...string) error {
+	return r.DelCtx(context.Background(), keys...)
+}
+
+func (r *Redis) DelCtx(ctx context.Context, keys ...string) error {
 	newKey := []string{}
 	for _, v := range keys {
 		v = r.getKey(v)
 		newKey = append(newKey, v)
 	}
-	return r.RedisClient.Del(newKey...).Err()
+	return r.RedisClient.Del(ctx, newKey...).Err()
 }
 
+// Deprecated: use DelPatternCtx
 func (r *Redis) DelPattern(pattern string) error {
-	pattern = r.getKey(pattern)
-	iter := r.RedisClient.Scan(0, pattern, 0).Iterator()
-
-	for iter.Next() {
-		err := r.RedisClient.Del(iter.Val()).Err()
-		if err != nil {
-			return err
-		}
-	}
-
-	if err := iter.Err(); err != nil {
-		return err
-	}
-
-	return nil
+	return r.DelPatternCtx(context.Background(), pattern)
 }
 
 func (r *Redis) getKey(key string) string {
 	return r.Prefix + key
 }
+
+func (r *Redis) getKeys(keys []string) []string {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = r.getKey(key)
+	}
+	return prefixed
+}
+
+// randomID returns a random hex string, unique across processes
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,145 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRedis returns a Redis connected to an in-process miniredis server
+func newTestRedis(t *testing.T) (*Redis, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	r, err := New(Options{
+		Host:   mr.Host(),
+		Port:   mr.Port(),
+		Prefix: "test:",
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = r.RedisClient.Close() })
+	return r, mr
+}
+
+type menu struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestNew_acl(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.RequireUserAuth("service", "secret")
+
+	_, err := New(Options{Host: mr.Host(), Port: mr.Port(), Username: "service", Password: "wrong"})
+	assert.Error(t, err)
+
+	r, err := New(Options{Host: mr.Host(), Port: mr.Port(), Username: "service", Password: "secret"})
+	require.NoError(t, err)
+	assert.NoError(t, r.HealthCheck(context.Background()))
+}
+
+func TestRedis_SetCtx(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	require.NoError(t, r.SetCtx(ctx, "menu", menu{ID: 1, Name: "lunch"}, 60))
+	stored, err := mr.Get("test:menu")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":1,"name":"lunch"}`, stored)
+	assert.Equal(t, time.Minute, mr.TTL("test:menu"))
+
+	var out menu
+	require.NoError(t, r.GetStructCtx(ctx, "menu", &out))
+	assert.Equal(t, menu{ID: 1, Name: "lunch"}, out)
+
+	assert.ErrorIs(t, r.SetCtx(ctx, "menu", nil, 60), errutil.ErrEmptyRedisKeyValue)
+	assert.ErrorIs(t, r.GetStructCtx(ctx, "missing", &out), redis.Nil)
+}
+
+func TestRedis_SetStructCtx(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	require.NoError(t, r.SetStructCtx(ctx, "menu", menu{ID: 2}, 30))
+	assert.Equal(t, 30*time.Second, mr.TTL("test:menu"))
+}
+
+func TestRedis_SetStringCtx(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+
+	require.NoError(t, r.SetStringCtx(ctx, "name", "klikit", 60))
+	value, err := r.GetCtx(ctx, "name")
+	require.NoError(t, err)
+	assert.Equal(t, "klikit", value)
+
+	_, err = r.GetCtx(ctx, "missing")
+	assert.ErrorIs(t, err, redis.Nil)
+}
+
+func TestRedis_counters(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	n, err := r.INCRCtx(ctx, "count")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	n, err = r.IncByCtx(ctx, "count", 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), n)
+	n, err = r.IncByCtx(ctx, "count", -2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), n)
+	count, err := r.GetIntCtx(ctx, "count")
+	require.NoError(t, err)
+	assert.Equal(t, 9, count)
+	assert.Equal(t, time.Duration(0), mr.TTL("test:count"))
+
+	require.NoError(t, mr.Set("test:name", "menu"))
+	_, err = r.INCRCtx(ctx, "name")
+	assert.Error(t, err)
+}
+
+func TestRedis_keys(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	for _, key := range []string{"menu:1", "menu:2", "order:1"} {
+		require.NoError(t, r.SetStringCtx(ctx, key, "v", 60))
+	}
+	assert.True(t, r.HasKeyCtx(ctx, "menu:1"))
+	assert.True(t, r.ExistsCtx(ctx, "order:1"))
+
+	require.NoError(t, r.DelPatternCtx(ctx, "menu:*"))
+	assert.False(t, mr.Exists("test:menu:1"))
+	assert.False(t, mr.Exists("test:menu:2"))
+	assert.True(t, mr.Exists("test:order:1"))
+
+	require.NoError(t, r.DelCtx(ctx, "order:1"))
+	assert.False(t, r.HasKeyCtx(ctx, "order:1"))
+}
+
+func TestRedis_deprecated_shims(t *testing.T) {
+	r, _ := newTestRedis(t)
+
+	require.NoError(t, r.Set("menu", menu{ID: 3}, 60))
+	require.NoError(t, r.SetString("name", "klikit", 60))
+	require.NoError(t, r.INCR("count"))
+
+	var out menu
+	require.NoError(t, r.GetStruct("menu", &out))
+	assert.Equal(t, 3, out.ID)
+	value, err := r.Get("name")
+	require.NoError(t, err)
+	assert.Equal(t, "klikit", value)
+	count, err := r.GetInt("count")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.True(t, r.HasKey("name"))
+	require.NoError(t, r.Del("name"))
+	assert.False(t, r.Exists("name"))
+}
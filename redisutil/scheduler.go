@@ -0,0 +1,239 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/mostakim64/golang-utils/logger"
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	delayedSuffix       = ":delayed"
+	delayedPayloadField = ":payloads"
+	defaultPollInterval = time.Second
+	promoteBatch        = 100
+	minCronLockTTL      = time.Minute
+)
+
+// promoteScript moves the due jobs from the delayed set to the stream, so each job is
+// enqueued once however many consumers poll.
+// KEYS[1] delayed set, KEYS[2] payloads, KEYS[3] stream, ARGV now in ms, limit, max len
+var promoteScript = redis.NewScript(`
+local ids = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+local maxLen = tonumber(ARGV[3])
+for _, id in ipairs(ids) do
+	local payload = redis.call("HGET", KEYS[2], id)
+	redis.call("ZREM", KEYS[1], id)
+	redis.call("HDEL", KEYS[2], id)
+	if payload then
+		if maxLen > 0 then
+			redis.call("XADD", KEYS[3], "MAXLEN", "~", maxLen, "*", "payload", payload, "scheduled_id", id)
+		else
+			redis.call("XADD", KEYS[3], "*", "payload", payload, "scheduled_id", id)
+		end
+	end
+end
+return #ids
+`)
+
+// CronJob enqueues Value on the Spec schedule, see Queue.RunCron
+type CronJob[T any] struct {
+	// Name identifies the job across instances
+	Name string
+	// Spec is a standard 5 field cron expression or a descriptor like @hourly or @every 10m.
+	// @every runs on multiples of its interval since a fixed epoch, not relative to the start
+	// of each instance, so every instance reaches the same runs.
+	Spec  string
+	Value T
+}
+
+/*
+EnqueueAt schedules the job to be enqueued at t and returns its schedule id, which can be
+passed to Cancel. Due jobs are enqueued by the running consumers of the queue within about
+ConsumerOptions.PollInterval.
+
+On a cluster the queue name must have a hash tag, e.g. "{jobs:reminder}", since the delayed
+jobs are kept in keys next to the stream.
+
+Example:
+
+	id, err := reminders.EnqueueAt(ctx, Reminder{OrderID: 12}, order.PickupAt.Add(-15*time.Minute))
+*/
+func (q *Queue[T]) EnqueueAt(ctx context.Context, value T, t time.Time) (string, error) {
+	b, err := q.redis.encoder.encode(value)
+	if err != nil {
+		return "", err
+	}
+
+	id := randomID()
+	_, err = q.redis.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.delayedPayloads(), id, b)
+		pipe.ZAdd(ctx, q.delayed(), redis.Z{Score: float64(t.UnixMilli()), Member: id})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// EnqueueIn schedules the job to be enqueued after delay, see EnqueueAt
+func (q *Queue[T]) EnqueueIn(ctx context.Context, value T, delay time.Duration) (string, error) {
+	return q.EnqueueAt(ctx, value, time.Now().Add(delay))
+}
+
+// Cancel removes a job scheduled with EnqueueAt, false means it was already enqueued or cancelled
+func (q *Queue[T]) Cancel(ctx context.Context, id string) (bool, error) {
+	var removed *redis.IntCmd
+	_, err := q.redis.RedisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.ZRem(ctx, q.delayed(), id)
+		pipe.HDel(ctx, q.delayedPayloads(), id)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return removed.Val() == 1, nil
+}
+
+/*
+RunCron enqueues the jobs on their schedule until ctx is done. It can run on every instance,
+a distributed lock per run makes sure each run is enqueued once.
+
+Example:
+
+	err := menus.RunCron(ctx, redisutil.CronJob[MenuChange]{
+		Name:  "breakfast-menu",
+		Spec:  "0 6 * * *",
+		Value: MenuChange{Menu: "breakfast"},
+	})
+*/
+func (q *Queue[T]) RunCron(ctx context.Context, jobs ...CronJob[T]) error {
+	schedules := make([]cron.Schedule, len(jobs))
+	for i, job := range jobs {
+		schedule, err := parseCronSpec(job.Spec)
+		if err != nil {
+			return err
+		}
+		schedules[i] = schedule
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	next := make([]time.Time, len(jobs))
+	now := time.Now()
+	for i, schedule := range schedules {
+		next[i] = schedule.Next(now)
+	}
+
+	for {
+		earliest := next[0]
+		for _, t := range next[1:] {
+			if t.Before(earliest) {
+				earliest = t
+			}
+		}
+
+		sleepCtx(ctx, time.Until(earliest))
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		for i, job := range jobs {
+			if next[i].After(time.Now()) {
+				continue
+			}
+			following := schedules[i].Next(next[i])
+			q.runCronJob(ctx, job, next[i], following)
+			next[i] = following
+		}
+	}
+}
+
+// alignedSchedule runs every delay on the multiples of delay since the zero time, unlike
+// cron.ConstantDelaySchedule which counts from the start of each instance
+type alignedSchedule struct {
+	delay time.Duration
+}
+
+func (s alignedSchedule) Next(t time.Time) time.Time {
+	return t.Truncate(s.delay).Add(s.delay)
+}
+
+// parseCronSpec parses the spec of a CronJob, @every is aligned so the instances share its runs
+func parseCronSpec(spec string) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, err
+	}
+	if every, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		return alignedSchedule{delay: every.Delay}, nil
+	}
+	return schedule, nil
+}
+
+// runCronJob enqueues the run at of the job unless another instance did
+func (q *Queue[T]) runCronJob(ctx context.Context, job CronJob[T], at, following time.Time) {
+	ttl := following.Sub(at)
+	if ttl < minCronLockTTL {
+		ttl = minCronLockTTL
+	}
+
+	// the lock is left to expire, so instances reaching the run later skip it
+	name := "cron:" + q.name + ":" + job.Name + ":" + strconv.FormatInt(at.Unix(), 10)
+	_, err := q.redis.TryLock(ctx, name, ttl, WithoutLockRenewal())
+	if errors.Is(err, errutil.ErrLockNotObtained) {
+		return
+	}
+	if err != nil {
+		logger.Warn("redisutil: failed to lock cron job ", job.Name, ": ", err)
+		return
+	}
+
+	if _, err := q.Enqueue(ctx, job.Value); err != nil {
+		logger.Error("redisutil: failed to enqueue cron job ", job.Name, ": ", err)
+	}
+}
+
+// promoteDue enqueues the delayed jobs which are due and returns their number
+func (q *Queue[T]) promoteDue(ctx context.Context) (int64, error) {
+	keys := []string{q.delayed(), q.delayedPayloads(), q.stream}
+	return promoteScript.Run(ctx, q.redis.RedisClient, keys, time.Now().UnixMilli(), promoteBatch, q.opts.MaxLen).Int64()
+}
+
+// promoteLoop enqueues the due delayed jobs every interval until ctx is done
+func (q *Queue[T]) promoteLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := q.promoteDue(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Warn("redisutil: failed to enqueue the due jobs of ", q.stream, ": ", err)
+		}
+		// a full batch means more jobs may be due
+		if n == promoteBatch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *Queue[T]) delayed() string {
+	return q.stream + delayedSuffix
+}
+
+func (q *Queue[T]) delayedPayloads() string {
+	return q.stream + delayedSuffix + delayedPayloadField
+}
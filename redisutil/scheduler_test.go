@@ -0,0 +1,134 @@
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueue_EnqueueAt(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	q := NewQueue[email](r, "jobs:reminder", QueueOptions{})
+
+	_, err := q.EnqueueAt(ctx, email{To: "due"}, time.Now().Add(-time.Second))
+	require.NoError(t, err)
+	later, err := q.EnqueueIn(ctx, email{To: "later"}, time.Hour)
+	require.NoError(t, err)
+
+	n, err := q.promoteDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	msgs, err := r.RedisClient.XRange(ctx, "test:jobs:reminder", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	var value email
+	require.NoError(t, q.decode(msgs[0], &value))
+	assert.Equal(t, "due", value.To)
+
+	cancelled, err := q.Cancel(ctx, later)
+	require.NoError(t, err)
+	assert.True(t, cancelled)
+	cancelled, err = q.Cancel(ctx, later)
+	require.NoError(t, err)
+	assert.False(t, cancelled)
+	assert.Zero(t, r.RedisClient.Exists(ctx, "test:jobs:reminder:delayed", "test:jobs:reminder:delayed:payloads").Val())
+}
+
+func TestQueue_promoteDue_concurrent(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	q := NewQueue[email](r, "jobs:reminder", QueueOptions{})
+	for i := 0; i < 50; i++ {
+		_, err := q.EnqueueAt(ctx, email{To: fmt.Sprint(i)}, time.Now())
+		require.NoError(t, err)
+	}
+
+	var promoted int64
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := q.promoteDue(ctx)
+			assert.NoError(t, err)
+			atomic.AddInt64(&promoted, n)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(50), promoted)
+	assert.Equal(t, int64(50), r.RedisClient.XLen(ctx, "test:jobs:reminder").Val())
+}
+
+func TestQueue_Consume_delayed(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	q := NewQueue[email](r, "jobs:reminder", QueueOptions{})
+
+	handled := make(chan time.Time, 1)
+	consume(t, q, func(ctx context.Context, job Job[email]) error {
+		handled <- time.Now()
+		return nil
+	}, ConsumerOptions{Block: 20 * time.Millisecond, PollInterval: 10 * time.Millisecond})
+
+	due := time.Now().Add(100 * time.Millisecond)
+	_, err := q.EnqueueAt(ctx, email{To: "a"}, due)
+	require.NoError(t, err)
+
+	select {
+	case at := <-handled:
+		assert.False(t, at.Before(due))
+	case <-time.After(2 * time.Second):
+		t.Fatal("delayed job was not handled")
+	}
+}
+
+func TestQueue_RunCron(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	q := NewQueue[email](r, "jobs:digest", QueueOptions{})
+
+	assert.Error(t, q.RunCron(ctx, CronJob[email]{Name: "digest", Spec: "not a spec"}))
+
+	// every instance reaching the same run enqueues it once
+	job := CronJob[email]{Name: "digest", Spec: "0 6 * * *", Value: email{To: "a"}}
+	at := time.Date(2026, 1, 1, 6, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		q.runCronJob(ctx, job, at, at.Add(24*time.Hour))
+	}
+	assert.Equal(t, int64(1), r.RedisClient.XLen(ctx, "test:jobs:digest").Val())
+
+	cronCtx, cancel := context.WithTimeout(ctx, 1500*time.Millisecond)
+	defer cancel()
+	require.NoError(t, q.RunCron(cronCtx, CronJob[email]{Name: "ping", Spec: "@every 1s", Value: email{To: "b"}}))
+	// @every runs on whole seconds, so the window holds one or two runs after the digest
+	n := r.RedisClient.XLen(ctx, "test:jobs:digest").Val()
+	assert.GreaterOrEqual(t, n, int64(2))
+	assert.LessOrEqual(t, n, int64(3))
+}
+
+func TestParseCronSpec(t *testing.T) {
+	every, err := parseCronSpec("@every 10m")
+	require.NoError(t, err)
+
+	// instances started at different times reach the same runs
+	first := every.Next(time.Date(2026, 1, 1, 6, 3, 12, 0, time.UTC))
+	second := every.Next(time.Date(2026, 1, 1, 6, 7, 45, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 1, 1, 6, 10, 0, 0, time.UTC), first)
+	assert.Equal(t, first, second)
+	assert.Equal(t, time.Date(2026, 1, 1, 6, 20, 0, 0, time.UTC), every.Next(first))
+
+	daily, err := parseCronSpec("0 6 * * *")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 1, 2, 6, 0, 0, 0, time.Local), daily.Next(time.Date(2026, 1, 1, 6, 0, 0, 0, time.Local)))
+
+	_, err = parseCronSpec("@every")
+	assert.Error(t, err)
+}
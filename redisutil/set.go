@@ -0,0 +1,45 @@
+package redisutil
+
+import (
+	"context"
+)
+
+// SAdd adds the members to the set at key and returns the number which were not members yet
+func (r *Redis) SAdd(ctx context.Context, key string, members ...interface{}) (int64, error) {
+	return r.RedisClient.SAdd(ctx, r.getKey(key), members...).Result()
+}
+
+// SRem removes the members from the set at key and returns the number removed
+func (r *Redis) SRem(ctx context.Context, key string, members ...interface{}) (int64, error) {
+	return r.RedisClient.SRem(ctx, r.getKey(key), members...).Result()
+}
+
+// SMembers returns the members of the set at key
+func (r *Redis) SMembers(ctx context.Context, key string) ([]string, error) {
+	return r.RedisClient.SMembers(ctx, r.getKey(key)).Result()
+}
+
+// SIsMember reports whether member is in the set at key
+func (r *Redis) SIsMember(ctx context.Context, key string, member interface{}) (bool, error) {
+	return r.RedisClient.SIsMember(ctx, r.getKey(key), member).Result()
+}
+
+// SCard returns the number of members of the set at key
+func (r *Redis) SCard(ctx context.Context, key string) (int64, error) {
+	return r.RedisClient.SCard(ctx, r.getKey(key)).Result()
+}
+
+// SInter returns the members in every set at keys, on a cluster the keys must share a hash slot
+func (r *Redis) SInter(ctx context.Context, keys ...string) ([]string, error) {
+	return r.RedisClient.SInter(ctx, r.getKeys(keys)...).Result()
+}
+
+// SUnion returns the members in any set at keys, on a cluster the keys must share a hash slot
+func (r *Redis) SUnion(ctx context.Context, keys ...string) ([]string, error) {
+	return r.RedisClient.SUnion(ctx, r.getKeys(keys)...).Result()
+}
+
+// SDiff returns the members of the first set not in the others, on a cluster the keys must share a hash slot
+func (r *Redis) SDiff(ctx context.Context, keys ...string) ([]string, error) {
+	return r.RedisClient.SDiff(ctx, r.getKeys(keys)...).Result()
+}
@@ -0,0 +1,48 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_set(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	added, err := r.SAdd(ctx, "tags:1", "vegan", "spicy", "vegan")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), added)
+	_, err = r.SAdd(ctx, "tags:2", "spicy", "halal")
+	require.NoError(t, err)
+	assert.True(t, mr.Exists("test:tags:1"))
+
+	isMember, err := r.SIsMember(ctx, "tags:1", "spicy")
+	require.NoError(t, err)
+	assert.True(t, isMember)
+
+	card, err := r.SCard(ctx, "tags:1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), card)
+
+	inter, err := r.SInter(ctx, "tags:1", "tags:2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spicy"}, inter)
+
+	union, err := r.SUnion(ctx, "tags:1", "tags:2")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"vegan", "spicy", "halal"}, union)
+
+	diff, err := r.SDiff(ctx, "tags:1", "tags:2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vegan"}, diff)
+
+	removed, err := r.SRem(ctx, "tags:1", "vegan")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	members, err := r.SMembers(ctx, "tags:1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spicy"}, members)
+}
@@ -0,0 +1,123 @@
+package redisutil
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ScoredMember is a member of a sorted set with its score
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// ZAdd adds the members to the sorted set at key or updates their score,
+// and returns the number of new members
+func (r *Redis) ZAdd(ctx context.Context, key string, members ...ScoredMember) (int64, error) {
+	return r.RedisClient.ZAdd(ctx, r.getKey(key), zMembers(members)...).Result()
+}
+
+// ZIncrBy adds incr to the score of member, added with score incr if missing, and returns the new score
+func (r *Redis) ZIncrBy(ctx context.Context, key, member string, incr float64) (float64, error) {
+	return r.RedisClient.ZIncrBy(ctx, r.getKey(key), incr, member).Result()
+}
+
+// ZScore returns the score of member, redis.Nil when it isn't in the sorted set
+func (r *Redis) ZScore(ctx context.Context, key, member string) (float64, error) {
+	return r.RedisClient.ZScore(ctx, r.getKey(key), member).Result()
+}
+
+// ZRem removes the members from the sorted set at key and returns the number removed
+func (r *Redis) ZRem(ctx context.Context, key string, members ...string) (int64, error) {
+	values := make([]interface{}, len(members))
+	for i, m := range members {
+		values[i] = m
+	}
+
+	return r.RedisClient.ZRem(ctx, r.getKey(key), values...).Result()
+}
+
+// ZCard returns the number of members of the sorted set at key
+func (r *Redis) ZCard(ctx context.Context, key string) (int64, error) {
+	return r.RedisClient.ZCard(ctx, r.getKey(key)).Result()
+}
+
+// ZRank returns the 0 based position of member by ascending score, redis.Nil when it isn't in the sorted set
+func (r *Redis) ZRank(ctx context.Context, key, member string) (int64, error) {
+	return r.RedisClient.ZRank(ctx, r.getKey(key), member).Result()
+}
+
+// ZRevRank returns the 0 based position of member by descending score, i.e. its leaderboard position,
+// redis.Nil when it isn't in the sorted set
+func (r *Redis) ZRevRank(ctx context.Context, key, member string) (int64, error) {
+	return r.RedisClient.ZRevRank(ctx, r.getKey(key), member).Result()
+}
+
+/*
+ZTop returns a page of the members with the highest scores, e.g. of a leaderboard.
+
+Example:
+
+	// second page of 10
+	players, err := redis.ZTop(ctx, "leaderboard:weekly", 10, 10)
+*/
+func (r *Redis) ZTop(ctx context.Context, key string, offset, limit int64) ([]ScoredMember, error) {
+	if limit <= 0 {
+		return []ScoredMember{}, nil
+	}
+
+	zs, err := r.RedisClient.ZRevRangeWithScores(ctx, r.getKey(key), offset, offset+limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return scoredMembers(zs), nil
+}
+
+// ZRangeByScore returns a page of the members with a score between min and max by ascending score.
+// min and max are inclusive, prefix them with ( to exclude them, or use -inf and +inf.
+// A limit of 0 returns every member from offset.
+func (r *Redis) ZRangeByScore(ctx context.Context, key, min, max string, offset, limit int64) ([]ScoredMember, error) {
+	return r.zRangeByScore(ctx, key, min, max, offset, limit, false)
+}
+
+// ZRevRangeByScore is ZRangeByScore by descending score
+func (r *Redis) ZRevRangeByScore(ctx context.Context, key, min, max string, offset, limit int64) ([]ScoredMember, error) {
+	return r.zRangeByScore(ctx, key, min, max, offset, limit, true)
+}
+
+func (r *Redis) zRangeByScore(ctx context.Context, key, min, max string, offset, limit int64, rev bool) ([]ScoredMember, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+
+	zs, err := r.RedisClient.ZRangeArgsWithScores(ctx, redis.ZRangeArgs{
+		Key:     r.getKey(key),
+		Start:   min,
+		Stop:    max,
+		ByScore: true,
+		Rev:     rev,
+		Offset:  offset,
+		Count:   limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return scoredMembers(zs), nil
+}
+
+func zMembers(members []ScoredMember) []redis.Z {
+	zs := make([]redis.Z, len(members))
+	for i, m := range members {
+		zs[i] = redis.Z{Member: m.Member, Score: m.Score}
+	}
+	return zs
+}
+
+func scoredMembers(zs []redis.Z) []ScoredMember {
+	members := make([]ScoredMember, len(zs))
+	for i, z := range zs {
+		members[i] = ScoredMember{Member: z.Member.(string), Score: z.Score}
+	}
+	return members
+}
@@ -0,0 +1,54 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_sorted_set(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+
+	added, err := r.ZAdd(ctx, "leaderboard", ScoredMember{"a", 10}, ScoredMember{"b", 30}, ScoredMember{"c", 20}, ScoredMember{"d", 40})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), added)
+	assert.True(t, mr.Exists("test:leaderboard"))
+
+	score, err := r.ZIncrBy(ctx, "leaderboard", "a", 25)
+	require.NoError(t, err)
+	assert.Equal(t, float64(35), score)
+	score, err = r.ZScore(ctx, "leaderboard", "a")
+	require.NoError(t, err)
+	assert.Equal(t, float64(35), score)
+
+	rank, err := r.ZRevRank(ctx, "leaderboard", "a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), rank)
+	rank, err = r.ZRank(ctx, "leaderboard", "a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rank)
+	_, err = r.ZRank(ctx, "leaderboard", "z")
+	assert.ErrorIs(t, err, redis.Nil)
+
+	top, err := r.ZTop(ctx, "leaderboard", 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []ScoredMember{{"a", 35}, {"b", 30}}, top)
+
+	page, err := r.ZRangeByScore(ctx, "leaderboard", "20", "+inf", 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []ScoredMember{{"b", 30}, {"a", 35}}, page)
+	page, err = r.ZRevRangeByScore(ctx, "leaderboard", "(20", "40", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []ScoredMember{{"d", 40}, {"a", 35}, {"b", 30}}, page)
+
+	removed, err := r.ZRem(ctx, "leaderboard", "d", "z")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	card, err := r.ZCard(ctx, "leaderboard")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), card)
+}
@@ -0,0 +1,164 @@
+package redisutil
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	tagKeyPrefix = "tag:"
+	// pruneBatch is the number of members checked per SSCAN by PruneTags
+	pruneBatch = 500
+)
+
+// setTaggedScript stores the value and adds the key to the set of every tag. A tag set lives as
+// long as its longest lived key, so the sets of expired entries go away too.
+// KEYS[1] key, KEYS[2..] tag sets, ARGV value, ttl in ms or 0 for no expiry
+var setTaggedScript = redis.NewScript(`
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ttl)
+else
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+for i = 2, #KEYS do
+	local current = redis.call("PTTL", KEYS[i])
+	redis.call("SADD", KEYS[i], KEYS[1])
+	if ttl == 0 then
+		redis.call("PERSIST", KEYS[i])
+	elseif current == -2 or (current >= 0 and current < ttl) then
+		redis.call("PEXPIRE", KEYS[i], ttl)
+	end
+end
+return 1
+`)
+
+// invalidateTagsScript deletes the keys of the tags and the tag sets, returns the number of keys deleted.
+// KEYS tag sets
+var invalidateTagsScript = redis.NewScript(`
+local deleted = 0
+for _, tag in ipairs(KEYS) do
+	local keys = redis.call("SMEMBERS", tag)
+	for i = 1, #keys, 500 do
+		deleted = deleted + redis.call("DEL", unpack(keys, i, math.min(i + 499, #keys)))
+	end
+	redis.call("DEL", tag)
+end
+return deleted
+`)
+
+// pruneTagScript removes the keys which don't exist from the tag set, checked and removed at
+// once so a key set again meanwhile stays in the set. Returns the number of keys removed.
+// KEYS[1] tag set, KEYS[2..] keys of the set
+var pruneTagScript = redis.NewScript(`
+local removed = 0
+for i = 2, #KEYS do
+	if redis.call("EXISTS", KEYS[i]) == 0 then
+		removed = removed + redis.call("SREM", KEYS[1], KEYS[i])
+	end
+end
+return removed
+`)
+
+/*
+InvalidateTags atomically deletes every key stored with one of the tags, see Cache.Set, and
+returns the number of keys deleted. Unlike DelPatternCtx the keyspace isn't scanned.
+
+The keys are deleted by a Lua script, so on a cluster the keys and their tags must share a
+hash slot, e.g. with the hash tag "{brand:12}" in both.
+
+Example:
+
+	err := menuCache.Set(ctx, "menu:brand:12:branch:3", menu, time.Hour, "brand:12", "branch:3")
+	deleted, err := redis.InvalidateTags(ctx, "brand:12")
+*/
+func (r *Redis) InvalidateTags(ctx context.Context, tags ...string) (int64, error) {
+	if len(tags) == 0 {
+		return 0, nil
+	}
+	return invalidateTagsScript.Run(ctx, r.RedisClient, r.tagKeys(tags)).Int64()
+}
+
+/*
+PruneTags removes the keys which no longer exist, because they expired or were deleted, from
+the sets of the tags, every tag when none is given, and returns the number of members removed.
+The tag sets expire with their longest lived key, so pruning only matters for long lived tags
+whose keys come and go. Like InvalidateTags, on a cluster the keys must share the hash slot of
+their tags.
+*/
+func (r *Redis) PruneTags(ctx context.Context, tags ...string) (int64, error) {
+	if len(tags) == 0 {
+		keys, err := r.ScanKeys(ctx, tagKeyPrefix+"*")
+		if err != nil {
+			return 0, err
+		}
+		for _, key := range keys {
+			tags = append(tags, strings.TrimPrefix(key, tagKeyPrefix))
+		}
+	}
+
+	var removed int64
+	for _, tag := range r.tagKeys(tags) {
+		n, err := r.pruneTag(ctx, tag)
+		removed += n
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+func (r *Redis) pruneTag(ctx context.Context, tag string) (int64, error) {
+	var removed int64
+	iter := r.RedisClient.SScan(ctx, tag, 0, "", pruneBatch).Iterator()
+	batch := make([]string, 0, pruneBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		n, err := pruneTagScript.Run(ctx, r.RedisClient, append([]string{tag}, batch...)).Int64()
+		removed += n
+		batch = batch[:0]
+		return err
+	}
+
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) >= pruneBatch {
+			if err := flush(); err != nil {
+				return removed, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return removed, err
+	}
+	return removed, flush()
+}
+
+// setTagged stores the encoded value at key, adding it to the tags if any
+func (r *Redis) setTagged(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string) error {
+	if len(tags) == 0 {
+		return r.RedisClient.Set(ctx, r.getKey(key), value, ttl).Err()
+	}
+
+	// the script takes the ttl in ms where 0 means no expiry, so a shorter ttl is rounded up
+	ms := ttl.Milliseconds()
+	if ttl > 0 && time.Duration(ms)*time.Millisecond < ttl {
+		ms++
+	}
+	keys := append([]string{r.getKey(key)}, r.tagKeys(tags)...)
+	return setTaggedScript.Run(ctx, r.RedisClient, keys, value, ms).Err()
+}
+
+func (r *Redis) tagKeys(tags []string) []string {
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = r.getKey(tagKeyPrefix + tag)
+	}
+	return keys
+}
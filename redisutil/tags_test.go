@@ -0,0 +1,115 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedis_InvalidateTags(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	c := NewCache[menu](r)
+
+	require.NoError(t, c.Set(ctx, "menu:brand:12:branch:1", menu{ID: 1}, time.Hour, "brand:12", "branch:1"))
+	require.NoError(t, c.Set(ctx, "menu:brand:12:branch:2", menu{ID: 2}, time.Hour, "brand:12", "branch:2"))
+	require.NoError(t, c.Set(ctx, "menu:brand:13:branch:3", menu{ID: 3}, time.Hour, "brand:13"))
+	require.NoError(t, c.Set(ctx, "menu:untagged", menu{ID: 4}, time.Hour))
+
+	members, err := mr.SMembers("test:tag:brand:12")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"test:menu:brand:12:branch:1", "test:menu:brand:12:branch:2"}, members)
+
+	deleted, err := r.InvalidateTags(ctx, "brand:12")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	assert.False(t, mr.Exists("test:menu:brand:12:branch:1"))
+	assert.False(t, mr.Exists("test:menu:brand:12:branch:2"))
+	assert.False(t, mr.Exists("test:tag:brand:12"))
+	assert.True(t, mr.Exists("test:menu:brand:13:branch:3"))
+	assert.True(t, mr.Exists("test:menu:untagged"))
+
+	// the other tags of a deleted key are left for PruneTags
+	deleted, err = r.InvalidateTags(ctx, "branch:1", "unknown")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+
+	deleted, err = r.InvalidateTags(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+}
+
+func TestCache_Set_tag_ttl(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	c := NewCache[menu](r)
+
+	// the tag lives as long as its longest lived key
+	require.NoError(t, c.Set(ctx, "menu:1", menu{ID: 1}, time.Hour, "brand:12"))
+	assert.Equal(t, time.Hour, mr.TTL("test:tag:brand:12"))
+	require.NoError(t, c.Set(ctx, "menu:2", menu{ID: 2}, time.Minute, "brand:12"))
+	assert.Equal(t, time.Hour, mr.TTL("test:tag:brand:12"))
+	require.NoError(t, c.Set(ctx, "menu:3", menu{ID: 3}, 2*time.Hour, "brand:12"))
+	assert.Equal(t, 2*time.Hour, mr.TTL("test:tag:brand:12"))
+	require.NoError(t, c.Set(ctx, "menu:4", menu{ID: 4}, 0, "brand:12"))
+	assert.Equal(t, time.Duration(0), mr.TTL("test:tag:brand:12"))
+	require.NoError(t, c.Set(ctx, "menu:5", menu{ID: 5}, time.Minute, "brand:12"))
+	assert.Equal(t, time.Duration(0), mr.TTL("test:tag:brand:12"))
+
+	// a ttl below 1ms still expires
+	require.NoError(t, c.Set(ctx, "menu:6", menu{ID: 6}, time.Microsecond, "brand:13"))
+	assert.Equal(t, time.Millisecond, mr.TTL("test:menu:6"))
+	assert.Equal(t, time.Millisecond, mr.TTL("test:tag:brand:13"))
+
+	value, ok, err := c.Get(ctx, "menu:1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, value.ID)
+	assert.Equal(t, time.Hour, mr.TTL("test:menu:1"))
+}
+
+func TestCache_GetOrLoad_tags(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	c := NewCache[menu](r)
+
+	_, err := c.GetOrLoad(ctx, "menu:1", time.Hour, func(ctx context.Context) (menu, error) {
+		return menu{ID: 1}, nil
+	}, WithTags("brand:12"))
+	require.NoError(t, err)
+	assert.True(t, mr.Exists("test:tag:brand:12"))
+
+	deleted, err := r.InvalidateTags(ctx, "brand:12")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.False(t, mr.Exists("test:menu:1"))
+}
+
+func TestRedis_PruneTags(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	c := NewCache[menu](r)
+
+	require.NoError(t, c.Set(ctx, "menu:1", menu{ID: 1}, time.Hour, "brand:12", "branch:1"))
+	require.NoError(t, c.Set(ctx, "menu:2", menu{ID: 2}, time.Minute, "brand:12"))
+	require.NoError(t, c.Set(ctx, "menu:3", menu{ID: 3}, time.Hour, "brand:13"))
+	mr.FastForward(2 * time.Minute)
+	require.NoError(t, c.Del(ctx, "menu:3"))
+
+	removed, err := r.PruneTags(ctx, "brand:12")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	members, err := mr.SMembers("test:tag:brand:12")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"test:menu:1"}, members)
+
+	// every tag
+	removed, err = r.PruneTags(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), removed)
+	assert.False(t, mr.Exists("test:tag:brand:13"))
+	assert.True(t, mr.Exists("test:tag:branch:1"))
+}
@@ -0,0 +1,250 @@
+package redisutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mostakim64/golang-utils/logger"
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultInvalidationChannel = "redisutil:invalidate"
+	defaultTieredTTL           = time.Minute
+)
+
+// TieredOptions configures the in-memory tier of a TieredCache
+type TieredOptions struct {
+	// MaxEntries limits the number of values kept in memory, 0 means no limit
+	MaxEntries int
+	// TTL is the longest a value is kept in memory, it is further capped by the redis ttl of
+	// the key. It bounds how stale a value changed without a TieredCache can be, 1 minute by default.
+	TTL time.Duration
+	// Channel is the pub/sub channel used to invalidate the other instances, prefixed with the Redis Prefix
+	Channel string
+}
+
+// invalidation is published when a key is set or deleted through a TieredCache
+type invalidation struct {
+	Source string   `json:"source"`
+	Keys   []string `json:"keys"`
+}
+
+// TieredCache keeps hot values of a Cache in an in-memory LRU in front of redis.
+// Set and Del through any TieredCache on the same channel evict the key from the memory
+// of every instance via redis pub/sub. Keys changed without a TieredCache are only
+// refreshed once their in-memory TTL expires.
+type TieredCache[T any] struct {
+	cache   *Cache[T]
+	local   *lru[T]
+	ttl     time.Duration
+	channel string
+	id      string
+	sub     *redis.PubSub
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	// mu orders the changes of local. generation counts them, a value read from redis is
+	// only kept in memory when the key wasn't invalidated or set during the read.
+	mu         sync.Mutex
+	generation uint64
+}
+
+/*
+NewTieredCache returns a TieredCache of T values stored in r and subscribes to the
+invalidation channel. Close must be called to stop the subscription.
+
+Example:
+
+	menuCache, err := redisutil.NewTieredCache[Menu](redis, redisutil.TieredOptions{MaxEntries: 1000, TTL: time.Minute})
+	defer menuCache.Close()
+*/
+func NewTieredCache[T any](r *Redis, opts TieredOptions) (*TieredCache[T], error) {
+	channel := opts.Channel
+	if channel == "" {
+		channel = defaultInvalidationChannel
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = defaultTieredTTL
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &TieredCache[T]{
+		cache:   NewCache[T](r),
+		local:   newLRU[T](opts.MaxEntries),
+		ttl:     opts.TTL,
+		channel: r.getKey(channel),
+		id:      randomID(),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	c.sub = r.RedisClient.Subscribe(ctx, c.channel)
+	if _, err := c.sub.Receive(ctx); err != nil {
+		cancel()
+		_ = c.sub.Close()
+		return nil, err
+	}
+	go c.listen(ctx)
+
+	return c, nil
+}
+
+// Get returns the value from memory, else from redis and keeps it in memory.
+// On a miss ok is false and err is errutil.ErrCacheMiss.
+func (c *TieredCache[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	if value, ok := c.local.get(key); ok {
+		c.cache.redis.observeCache(true)
+		return value, true, nil
+	}
+
+	var value T
+	if key == "" {
+		return value, false, errutil.ErrEmptyRedisKeyValue
+	}
+
+	generation := c.currentGeneration()
+	redisKey := c.cache.redis.getKey(key)
+	var getCmd *redis.StringCmd
+	var ttlCmd *redis.DurationCmd
+	_, err := c.cache.redis.RedisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		getCmd = pipe.Get(ctx, redisKey)
+		ttlCmd = pipe.PTTL(ctx, redisKey)
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		c.cache.redis.observeCache(false)
+		return value, false, errutil.ErrCacheMiss
+	}
+	if err != nil {
+		return value, false, err
+	}
+
+	b, err := getCmd.Bytes()
+	if err != nil {
+		return value, false, err
+	}
+	if err := c.cache.redis.encoder.decode(b, &value); err != nil {
+		return value, false, err
+	}
+
+	c.fill(key, value, c.localTTL(ttlCmd.Val()), generation)
+	c.cache.redis.observeCache(true)
+	return value, true, nil
+}
+
+// Set stores the value in redis and memory and evicts the key from the other instances
+func (c *TieredCache[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if err := c.cache.Set(ctx, key, value, ttl); err != nil {
+		c.invalidate(key)
+		return err
+	}
+
+	c.mu.Lock()
+	c.generation++
+	c.local.set(key, value, c.localTTL(ttl))
+	c.mu.Unlock()
+	return c.publish(ctx, key)
+}
+
+// Del removes the keys from redis and memory and evicts them from the other instances
+func (c *TieredCache[T]) Del(ctx context.Context, keys ...string) error {
+	c.invalidate(keys...)
+	if err := c.cache.Del(ctx, keys...); err != nil {
+		return err
+	}
+
+	return c.publish(ctx, keys...)
+}
+
+// Close stops listening for invalidations
+func (c *TieredCache[T]) Close() error {
+	c.cancel()
+	// closing the subscription unblocks the pending Receive of listen
+	err := c.sub.Close()
+	<-c.done
+	return err
+}
+
+// currentGeneration returns the generation to pass to fill after reading redis
+func (c *TieredCache[T]) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// fill keeps the value read from redis in memory, unless local changed since generation:
+// the value may predate an invalidation received during the read.
+func (c *TieredCache[T]) fill(key string, value T, ttl time.Duration, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation == generation {
+		c.local.set(key, value, ttl)
+	}
+}
+
+// invalidate evicts the keys from memory, every key when none is given
+func (c *TieredCache[T]) invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if len(keys) == 0 {
+		c.local.purge()
+	} else {
+		c.local.remove(keys...)
+	}
+}
+
+// localTTL caps the in-memory ttl by the redis ttl, a redis ttl <= 0 means no expiry
+func (c *TieredCache[T]) localTTL(redisTTL time.Duration) time.Duration {
+	if redisTTL > 0 && redisTTL < c.ttl {
+		return redisTTL
+	}
+	return c.ttl
+}
+
+func (c *TieredCache[T]) publish(ctx context.Context, keys ...string) error {
+	msg, err := json.Marshal(invalidation{Source: c.id, Keys: keys})
+	if err != nil {
+		return err
+	}
+
+	return c.cache.redis.RedisClient.Publish(ctx, c.channel, msg).Err()
+}
+
+// listen evicts the keys invalidated by the other instances. Messages may be lost
+// while the subscription reconnects, so the memory is purged on every reconnect.
+func (c *TieredCache[T]) listen(ctx context.Context) {
+	defer close(c.done)
+
+	for {
+		msg, err := c.sub.Receive(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			c.invalidate()
+			logger.Warn("redisutil: invalidation subscription failed: ", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			c.invalidate()
+		case *redis.Message:
+			var inv invalidation
+			if err := json.Unmarshal([]byte(m.Payload), &inv); err != nil {
+				logger.Warn("redisutil: invalid invalidation message: ", err)
+				continue
+			}
+			if inv.Source != c.id && len(inv.Keys) > 0 {
+				c.invalidate(inv.Keys...)
+			}
+		}
+	}
+}
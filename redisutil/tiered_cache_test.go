@@ -0,0 +1,163 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mostakim64/golang-utils/redisutil/errutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTieredCache(t *testing.T, r *Redis, opts TieredOptions) *TieredCache[menu] {
+	c, err := NewTieredCache[menu](r, opts)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return c
+}
+
+func TestTieredCache_Get(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	c := newTieredCache(t, r, TieredOptions{TTL: time.Minute})
+
+	_, ok, err := c.Get(ctx, "menu:1")
+	assert.False(t, ok)
+	assert.ErrorIs(t, err, errutil.ErrCacheMiss)
+
+	require.NoError(t, c.Set(ctx, "menu:1", menu{ID: 1}, time.Minute))
+	// served from memory even when redis changed behind the cache
+	require.NoError(t, mr.Set("test:menu:1", `{"id":2}`))
+	value, ok, err := c.Get(ctx, "menu:1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, value.ID)
+}
+
+func TestTieredCache_default_ttl(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	c := newTieredCache(t, r, TieredOptions{MaxEntries: 1000})
+	assert.Equal(t, defaultTieredTTL, c.ttl)
+
+	// the values are kept in memory without a TTL option
+	require.NoError(t, c.Set(ctx, "menu:1", menu{ID: 1}, time.Hour))
+	require.NoError(t, mr.Set("test:menu:1", `{"id":2}`))
+	value, ok, err := c.Get(ctx, "menu:1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, value.ID)
+}
+
+func TestTieredCache_ttl_is_capped_by_redis(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	c := newTieredCache(t, r, TieredOptions{TTL: time.Minute})
+
+	require.NoError(t, mr.Set("test:menu:1", `{"id":1}`))
+	mr.SetTTL("test:menu:1", 30*time.Millisecond)
+	_, ok, err := c.Get(ctx, "menu:1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, mr.Set("test:menu:1", `{"id":2}`))
+	time.Sleep(40 * time.Millisecond)
+	value, _, err := c.Get(ctx, "menu:1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, value.ID)
+}
+
+func TestTieredCache_max_entries(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	c := newTieredCache(t, r, TieredOptions{TTL: time.Minute, MaxEntries: 2})
+
+	for _, key := range []string{"menu:1", "menu:2", "menu:3"} {
+		require.NoError(t, c.Set(ctx, key, menu{}, time.Minute))
+	}
+	assert.Equal(t, 2, c.local.len())
+	_, ok := c.local.get("menu:1")
+	assert.False(t, ok)
+}
+
+func TestTieredCache_invalidates_other_instances(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestRedis(t)
+	first := newTieredCache(t, r, TieredOptions{TTL: time.Minute})
+	second := newTieredCache(t, r, TieredOptions{TTL: time.Minute})
+
+	require.NoError(t, first.Set(ctx, "menu:1", menu{ID: 1}, time.Minute))
+	value, _, err := second.Get(ctx, "menu:1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, value.ID)
+
+	require.NoError(t, first.Set(ctx, "menu:1", menu{ID: 2}, time.Minute))
+	assert.Eventually(t, func() bool {
+		value, _, err := second.Get(ctx, "menu:1")
+		return err == nil && value.ID == 2
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, second.Del(ctx, "menu:1"))
+	assert.Eventually(t, func() bool {
+		_, ok, _ := first.Get(ctx, "menu:1")
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+
+	// the instance keeps its own writes in memory
+	_, ok := second.local.get("menu:1")
+	assert.False(t, ok)
+	require.NoError(t, first.Set(ctx, "menu:1", menu{ID: 3}, time.Minute))
+	time.Sleep(20 * time.Millisecond)
+	value, ok = first.local.get("menu:1")
+	assert.True(t, ok)
+	assert.Equal(t, 3, value.ID)
+}
+
+// afterPipeline calls fn once a pipeline got its replies
+type afterPipeline struct {
+	fn func()
+}
+
+func (h afterPipeline) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h afterPipeline) ProcessHook(next redis.ProcessHook) redis.ProcessHook { return next }
+
+func (h afterPipeline) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		h.fn()
+		return err
+	}
+}
+
+func TestTieredCache_invalidation_during_read(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestRedis(t)
+	c := newTieredCache(t, r, TieredOptions{TTL: time.Minute})
+	require.NoError(t, mr.Set("test:menu:1", `{"id":1}`))
+
+	// the key is updated and invalidated after Get read the old value from redis
+	invalidated := false
+	r.RedisClient.AddHook(afterPipeline{fn: func() {
+		if !invalidated {
+			invalidated = true
+			require.NoError(t, mr.Set("test:menu:1", `{"id":2}`))
+			c.invalidate("menu:1")
+		}
+	}})
+
+	value, ok, err := c.Get(ctx, "menu:1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, value.ID)
+	_, ok = c.local.get("menu:1")
+	assert.False(t, ok, "a value read before an invalidation is not kept")
+
+	value, _, err = c.Get(ctx, "menu:1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, value.ID)
+	_, ok = c.local.get("menu:1")
+	assert.True(t, ok)
+}
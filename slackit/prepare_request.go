@@ -109,7 +109,14 @@ func PrepareAttachmentBody(req ClientRequest) []Attachments {
 
 	serviceLogTimeField := addField("mrkdwn", "*Created At:*\n"+currentTimeStr)
 
-	serviceInfoBlock := addSectionBlock([]*Fields{serviceNameField, serviceLogTimeField})
+	serviceInfoFields := []*Fields{serviceNameField, serviceLogTimeField}
+
+	if req.TraceUrl != "" {
+		traceField := addField("mrkdwn", "*Trace:*\n<"+req.TraceUrl+"|View trace>")
+		serviceInfoFields = append(serviceInfoFields, traceField)
+	}
+
+	serviceInfoBlock := addSectionBlock(serviceInfoFields)
 
 	summaryField := addField("mrkdwn", "*Summary:*\n"+summary)
 
@@ -12,6 +12,7 @@ type ClientRequest struct {
 	Details     string   `json:"details"`
 	Status      int      `json:"status"`
 	Mentions    []string `json:"mentions"`
+	TraceUrl    string   `json:"trace_url"`
 }
 
 func (req *ClientRequest) Validate() error {